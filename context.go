@@ -3,11 +3,16 @@ package goldpdf
 import (
 	"bytes"
 	"image/color"
+	"math"
+	"strings"
 
 	"github.com/jung-kurt/gofpdf"
 )
 
-var _ RenderContext = &renderContextImpl{}
+var (
+	_ RenderContext  = &renderContextImpl{}
+	_ MeasureContext = unpaginatedContext{}
+)
 
 // MeasureContext provides a way to measure the dimensions of the drawing element.
 type MeasureContext interface {
@@ -25,9 +30,25 @@ type RenderContext interface {
 	DrawBox(rect Rect, bgColor color.Color, border Border)
 }
 
+// renderContextImpl is the RenderContext drawing to a gofpdf.Fpdf.
+// Its methods that are not a part of RenderContext, such as AddLink, are called through type assertions
+// so that adding them does not break the other implementations of the exported interfaces.
 type renderContextImpl struct {
-	fpdf        *gofpdf.Fpdf
-	inRendering bool
+	fpdf           *gofpdf.Fpdf
+	reserved       func(page int) (float64, float64) // heights reserved at the top and bottom of the page
+	syntheticStyle func(TextFormat) (bool, bool)     // whether the bold and italic of the format are synthesized
+	inRendering    bool
+	destinations   map[string]VerticalCoord
+	internalLinks  []internalLink
+	bookmarkLevels []int
+}
+
+// internalLink is a link to a named destination in the document.
+// Since the destination may appear after the link, it is resolved at the end of rendering.
+type internalLink struct {
+	page       int
+	x, y, w, h float64
+	name       string
 }
 
 func (p *renderContextImpl) GetTextWidth(span *TextElement) float64 {
@@ -35,6 +56,17 @@ func (p *renderContextImpl) GetTextWidth(span *TextElement) float64 {
 	return p.fpdf.GetStringWidth(span.Text)
 }
 
+// GetFontMetrics returns the ascent and descent of the font.
+// Since the core fonts have no metrics in gofpdf, 80% and 20% of the font size are used for them.
+func (p *renderContextImpl) GetFontMetrics(format TextFormat) (float64, float64) {
+	p.applyTextFormat(format)
+	desc := p.fpdf.GetFontDesc("", "")
+	if desc.Ascent == 0 {
+		return format.FontSize * 0.8, format.FontSize * 0.2
+	}
+	return format.FontSize * float64(desc.Ascent) / 1000, -format.FontSize * float64(desc.Descent) / 1000
+}
+
 func (p *renderContextImpl) GetSubText(span *TextElement, width float64) *TextElement {
 	p.applyTextFormat(span.Format)
 	width += span.Format.FontSize / 2 // SplitText issue
@@ -44,18 +76,28 @@ func (p *renderContextImpl) GetSubText(span *TextElement, width float64) *TextEl
 		return nil
 	}
 
-	ss := &TextElement{Text: lines[0], Format: span.Format}
-	if p.GetTextWidth(ss) > width { // SplitText issue
+	ss := *span
+	ss.Text = lines[0]
+	if p.GetTextWidth(&ss) > width { // SplitText issue
 		return nil
 	}
 
-	return ss
+	return &ss
 }
 
+// GetPageVerticalBounds returns the top and bottom of the area where the body is laid out,
+// excluding the areas reserved for headers, footers and footnotes.
+// It does not add the page to the PDF, so that probing a page never leaves a blank page.
 func (p *renderContextImpl) GetPageVerticalBounds(page int) (float64, float64) {
-	p.setPage(page)
+	// The pages are added by AddPage in the default orientation and size,
+	// which GetPageSize returns as gofpdf keeps the size of the last added page.
 	_, h := p.fpdf.GetPageSize()
 	_, tm, _, bm := p.fpdf.GetMargins()
+	if p.reserved != nil {
+		top, bottom := p.reserved(page)
+		tm += top
+		bm += bottom
+	}
 	return tm, h - bm
 }
 
@@ -75,14 +117,37 @@ func (rc *renderContextImpl) GetRenderContext(fn func(RenderContext) error) erro
 
 func (p *renderContextImpl) DrawText(page int, x, y float64, span *TextElement) {
 	p.setPage(page)
+	ascent, descent := p.GetFontMetrics(span.Format)
 	rect := Rect{
 		Left:   x,
 		Right:  x + p.GetTextWidth(span),
-		Top:    VerticalCoord{Page: page, Position: y},
-		Bottom: VerticalCoord{Page: page, Position: y + span.Format.FontSize},
+		Top:    VerticalCoord{Page: page, Position: y + span.Format.FontSize - ascent},
+		Bottom: VerticalCoord{Page: page, Position: y + span.Format.FontSize + descent},
 	}
 	p.DrawBox(rect, span.Format.BackgroundColor, span.Format.Border)
 	p.applyTextFormat(span.Format)
+
+	var fakeBold, fakeItalic bool
+	if p.syntheticStyle != nil {
+		fakeBold, fakeItalic = p.syntheticStyle(span.Format)
+	}
+	if fakeItalic {
+		p.fpdf.TransformBegin()
+		p.fpdf.TransformSkewX(12, x, y+span.Format.FontSize)
+		defer p.fpdf.TransformEnd()
+	}
+	if fakeBold {
+		// Stroke the outlines of the glyphs in addition to filling them
+		lineWidth := p.fpdf.GetLineWidth()
+		p.colorHelper(span.Format.Color, p.fpdf.SetDrawColor)
+		p.fpdf.SetLineWidth(span.Format.FontSize / 30)
+		p.fpdf.SetTextRenderingMode(2)
+		defer func() {
+			p.fpdf.SetTextRenderingMode(0)
+			p.fpdf.SetLineWidth(lineWidth)
+		}()
+	}
+
 	p.fpdf.Text(x, y+span.Format.FontSize, span.Text)
 }
 
@@ -102,6 +167,63 @@ func (p *renderContextImpl) DrawBullet(page int, x, y float64, c color.Color, r
 	}
 }
 
+// AddLink makes the specified area of the page a clickable link to dest.
+// If dest starts with "#", the link points to the named destination registered by AddDestination.
+func (p *renderContextImpl) AddLink(page int, x, y, w, h float64, dest string) {
+	if strings.HasPrefix(dest, "#") {
+		p.internalLinks = append(p.internalLinks, internalLink{page: page, x: x, y: y, w: w, h: h, name: dest[1:]})
+		return
+	}
+	p.setPage(page)
+	p.fpdf.LinkString(x, y, w, h, dest)
+}
+
+// AddDestination registers a named destination that can be referenced by AddLink as "#name".
+func (p *renderContextImpl) AddDestination(name string, vc VerticalCoord) {
+	if p.destinations == nil {
+		p.destinations = map[string]VerticalCoord{}
+	}
+	if _, ok := p.destinations[name]; !ok { // the first one wins, as in HTML
+		p.destinations[name] = vc
+	}
+}
+
+// AddBookmark adds an entry pointing to vc to the document outline.
+// level is a heading level starting from 1; skipped levels are not nested
+// so that the outline remains a valid tree.
+func (p *renderContextImpl) AddBookmark(title *TextElement, level int, vc VerticalCoord) {
+	for len(p.bookmarkLevels) != 0 && p.bookmarkLevels[len(p.bookmarkLevels)-1] >= level {
+		p.bookmarkLevels = p.bookmarkLevels[:len(p.bookmarkLevels)-1]
+	}
+	depth := len(p.bookmarkLevels)
+	p.bookmarkLevels = append(p.bookmarkLevels, level)
+
+	p.setPage(vc.Page)
+	p.applyTextFormat(title.Format) // Bookmark encodes the title according to the current font
+	p.fpdf.Bookmark(title.Text, depth, vc.Position)
+}
+
+// resolveLinks writes the internal links whose destination has been registered.
+// Links to unknown destinations are dropped.
+func (p *renderContextImpl) resolveLinks() {
+	ids := map[string]int{}
+	for _, l := range p.internalLinks {
+		vc, ok := p.destinations[l.name]
+		if !ok {
+			continue
+		}
+		id, ok := ids[l.name]
+		if !ok {
+			id = p.fpdf.AddLink()
+			p.fpdf.SetLink(id, vc.Position, vc.Page)
+			ids[l.name] = id
+		}
+		p.setPage(l.page)
+		p.fpdf.Link(l.x, l.y, l.w, l.h, id)
+	}
+	p.internalLinks = nil
+}
+
 func (p *renderContextImpl) DrawBox(rect Rect, bgColor color.Color, border Border) {
 	x := rect.Left
 	w := rect.Right - rect.Left
@@ -119,6 +241,30 @@ func (p *renderContextImpl) DrawBox(rect Rect, bgColor color.Color, border Borde
 	}
 }
 
+// DrawCheckMark draws a check mark that fits in the rect.
+func (p *renderContextImpl) DrawCheckMark(rect Rect, c color.Color) {
+	if _, _, _, ca := c.RGBA(); ca != 0 {
+		p.setPage(rect.Top.Page)
+		x, y := rect.Left, rect.Top.Position
+		w, h := rect.Width(), rect.Bottom.Position-rect.Top.Position
+
+		p.fpdf.SetLineWidth(math.Min(w, h) / 6)
+		p.fpdf.SetLineCapStyle("round")
+		p.fpdf.SetLineJoinStyle("round")
+		p.colorHelper(c, p.fpdf.SetDrawColor)
+		p.fpdf.MoveTo(x+w*0.2, y+h*0.55)
+		p.fpdf.LineTo(x+w*0.42, y+h*0.78)
+		p.fpdf.LineTo(x+w*0.8, y+h*0.25)
+		p.fpdf.DrawPath("D")
+		p.fpdf.SetLineCapStyle("butt")
+		p.fpdf.SetLineJoinStyle("miter")
+	}
+}
+
+func (p *renderContextImpl) DrawLine(page int, x1, y1, x2, y2 float64, edge BorderEdge) {
+	p.drawEdge(page, x1, y1, x2, y2, edge)
+}
+
 func (p *renderContextImpl) drawBoxInPage(page int, x, y, w, h float64, bgColor color.Color, border Border) {
 	p.setPage(page)
 
@@ -193,3 +339,28 @@ func (p *renderContextImpl) colorHelper(c color.Color, fn func(int, int, int)) {
 	p.fpdf.SetAlpha(float64(ca)/0xFFFF, "")
 	fn(int(cr>>8), int(cg>>8), int(cb>>8))
 }
+
+// unpaginatedContext is a MeasureContext that measures elements on an endless page without drawing them.
+type unpaginatedContext struct {
+	MeasureContext
+}
+
+func (c unpaginatedContext) GetPageVerticalBounds(page int) (float64, float64) {
+	return 0, math.Inf(1)
+}
+
+func (c unpaginatedContext) GetRenderContext(fn func(RenderContext) error) error {
+	return nil
+}
+
+// getFontMetrics returns the distances from the baseline to the top and bottom of the font of the format.
+// If the MeasureContext cannot read the font, 80% and 20% of the font size are used.
+func getFontMetrics(mc MeasureContext, format TextFormat) (float64, float64) {
+	if c, ok := mc.(unpaginatedContext); ok {
+		mc = c.MeasureContext
+	}
+	if p, ok := mc.(*renderContextImpl); ok {
+		return p.GetFontMetrics(format)
+	}
+	return format.FontSize * 0.8, format.FontSize * 0.2
+}
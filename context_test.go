@@ -0,0 +1,76 @@
+package goldpdf
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+func TestResolveLinks(t *testing.T) {
+	fpdf := gofpdf.New("P", "pt", "A4", "")
+	fpdf.SetCompression(false)
+	fpdf.AddPage()
+	fpdf.AddPage()
+	rc := &renderContextImpl{fpdf: fpdf}
+
+	// Links are resolved at the end, so a link can point to a destination registered after it
+	rc.AddLink(1, 10, 10, 50, 12, "#later")
+	rc.AddLink(1, 10, 30, 50, 12, "#unknown")
+	rc.AddLink(1, 10, 50, 50, 12, "https://example.com/")
+	rc.AddDestination("later", VerticalCoord{Page: 2, Position: 100})
+	rc.AddDestination("later", VerticalCoord{Page: 1, Position: 200}) // ignored since the first one wins
+	rc.resolveLinks()
+
+	fpdf.SetPage(fpdf.PageCount())
+	buf := &bytes.Buffer{}
+	if err := fpdf.Output(buf); err != nil {
+		t.Fatal(err)
+	}
+	output := buf.String()
+
+	// The destination is the second page object, at 100pt from the top of the page
+	_, pageHeight := fpdf.GetPageSize()
+	pages := regexp.MustCompile(`(\d+) 0 obj\s*<</Type /Page\n`).FindAllStringSubmatch(output, -1)
+	if len(pages) != 2 {
+		t.Fatalf("the PDF has %d pages, want 2", len(pages))
+	}
+	dests := regexp.MustCompile(`/Dest \[(\d+) 0 R /XYZ 0 ([\d.]+) null\]`).FindAllStringSubmatch(output, -1)
+	if len(dests) != 1 {
+		t.Fatalf("%d internal links are written, want 1", len(dests))
+	}
+	if y, _ := strconv.ParseFloat(dests[0][2], 64); dests[0][1] != pages[1][1] || math.Abs(y-(pageHeight-100)) > 0.01 {
+		t.Errorf("the internal link points to object %s at %s, want object %s at %v", dests[0][1], dests[0][2], pages[1][1], pageHeight-100)
+	}
+	if !strings.Contains(output, "/URI (https://example.com/)") {
+		t.Errorf("the external link is not written")
+	}
+}
+
+func TestAddBookmark(t *testing.T) {
+	_, output := renderMarkdown(t, "# A\n\n## B\n\n#### C\n\n# D\n", WithOutline())
+
+	// The parent of each outline item by its title, where the skipped heading level is not nested
+	titles := map[string]string{}
+	parents := map[string]string{}
+	for _, m := range regexp.MustCompile(`(\d+) 0 obj\s*<</Title \((\w+)\)\s*/Parent (\d+) 0 R`).FindAllStringSubmatch(output, -1) {
+		titles[m[1]] = m[2]
+		parents[m[2]] = m[3]
+	}
+	got := ""
+	for _, title := range []string{"A", "B", "C", "D"} {
+		got += fmt.Sprintf("%s<%s ", title, titles[parents[title]])
+	}
+	if want := "A< B<A C<B D< "; got != want {
+		t.Errorf("the outline is %q, want %q", got, want)
+	}
+
+	if _, output := renderMarkdown(t, "# A\n"); strings.Contains(output, "/Title (A)") {
+		t.Errorf("the outline is written without WithOutline")
+	}
+}
@@ -3,12 +3,19 @@ package goldpdf
 import (
 	"math"
 	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	xast "github.com/yuin/goldmark/extension/ast"
 )
 
 // InlineElement は PDFに描画されるインラインの要素であり、テキストか画像の2種類があります
 type InlineElement interface {
 	String() string
 	size(mc MeasureContext) (float64, float64)
+	// metrics returns the heights of the element above and below the baseline of the line.
+	// The height of size is their sum.
+	metrics(mc MeasureContext) (float64, float64)
 	drawTo(rc RenderContext, page int, x, y float64)
 }
 
@@ -20,16 +27,41 @@ var (
 
 // TextElement は、単一のテキストフォーマットが設定された改行を含まないテキストを持つインライン要素です
 type TextElement struct {
-	Format TextFormat
-	Text   string
+	Format   TextFormat
+	Text     string
+	Link     string         // destination of the link to which the text belongs
+	footnote *xast.Footnote // footnote referenced by the text
 }
 
 func (s *TextElement) size(mc MeasureContext) (float64, float64) {
-	return mc.GetTextWidth(s), s.Format.FontSize
+	above, below := s.metrics(mc)
+	return mc.GetTextWidth(s), above + below
+}
+
+// metrics returns the ascent and descent of the font with the half-leading of the line height added,
+// shifted by the baseline shift.
+func (s *TextElement) metrics(mc MeasureContext) (float64, float64) {
+	ascent, descent := getFontMetrics(mc, s.Format)
+	halfLeading := 0.0
+	if s.Format.LineHeight != 0 {
+		halfLeading = (s.Format.LineHeight*s.Format.FontSize - ascent - descent) / 2
+	}
+	return ascent + halfLeading + s.Format.BaselineShift, descent + halfLeading - s.Format.BaselineShift
 }
 
 func (t *TextElement) drawTo(rc RenderContext, page int, x, y float64) {
-	rc.DrawText(page, x, y, t)
+	// DrawText takes the position of the baseline minus the font size
+	above, _ := t.metrics(rc)
+	rc.DrawText(page, x, y+above-t.Format.BaselineShift-t.Format.FontSize, t)
+	if rc, ok := rc.(*renderContextImpl); ok {
+		if t.footnote != nil {
+			rc.AddDestination(footnoteReferenceDestination(t.footnote.Index), VerticalCoord{Page: page, Position: y})
+		}
+		if t.Link != "" {
+			w, h := t.size(rc)
+			rc.AddLink(page, x, y, w, h, t.Link)
+		}
+	}
 }
 
 func (t *TextElement) String() string {
@@ -42,7 +74,12 @@ type LineBreakElement struct {
 }
 
 func (s *LineBreakElement) size(mc MeasureContext) (float64, float64) {
-	return 0, s.Format.FontSize
+	above, below := s.metrics(mc)
+	return 0, above + below
+}
+
+func (s *LineBreakElement) metrics(mc MeasureContext) (float64, float64) {
+	return (&TextElement{Format: s.Format}).metrics(mc)
 }
 
 func (t *LineBreakElement) drawTo(rc RenderContext, page int, x, y float64) {
@@ -58,33 +95,88 @@ type ImageElement struct {
 	ImageType     string // see ImageType of fpdf.ImageOptions
 	Width, Height float64
 	Bytes         []byte
+	Link          string // destination of the link to which the image belongs
 }
 
 func (i *ImageElement) size(MeasureContext) (float64, float64) {
 	return i.Width, i.Height
 }
 
+// metrics places the bottom of the image on the baseline.
+func (i *ImageElement) metrics(MeasureContext) (float64, float64) {
+	return i.Height, 0
+}
+
 func (i *ImageElement) drawTo(rc RenderContext, page int, x float64, y float64) {
 	rc.DrawImage(page, x, y, i)
+	if rc, ok := rc.(*renderContextImpl); ok && i.Link != "" {
+		rc.AddLink(page, x, y, i.Width, i.Height, i.Link)
+	}
 }
 func (t *ImageElement) String() string {
 	return "[image]"
 }
 
+// spacerElement is an invisible inline element that only occupies horizontal space.
+// It is inserted into lines to justify them.
+type spacerElement struct {
+	width float64
+}
+
+func (s *spacerElement) size(MeasureContext) (float64, float64) {
+	return s.width, 0
+}
+
+func (s *spacerElement) metrics(MeasureContext) (float64, float64) {
+	return 0, 0
+}
+
+func (s *spacerElement) drawTo(RenderContext, int, float64, float64) {}
+
+func (s *spacerElement) String() string {
+	return ""
+}
+
+// getLineSize returns the width of the line and its height, which spans the elements placed on a common baseline.
 func getLineSize(mc MeasureContext, line []InlineElement) (float64, float64) {
-	var width, height float64
+	var width, above, below float64
 	for _, e := range line {
-		w, h := e.size(mc)
+		w, _ := e.size(mc)
+		a, b := e.metrics(mc)
 		width += w
-		height = math.Max(height, h)
+		above = math.Max(above, a)
+		below = math.Max(below, b)
+	}
+	return width, above + below
+}
+
+// getLineBaseline returns the distance from the top of the line to its baseline.
+func getLineBaseline(mc MeasureContext, line []InlineElement) float64 {
+	baseline := 0.0
+	for _, e := range line {
+		a, _ := e.metrics(mc)
+		baseline = math.Max(baseline, a)
 	}
-	return width, height
+	return baseline
 }
 
-func wrapElements(mc MeasureContext, limitWidth float64, elements []InlineElement) [][]InlineElement {
+// wrapElements splits the elements into lines that fit in limitWidth.
+// Lines are not wrapped if bs.WhiteSpace does not allow it.
+func wrapElements(mc MeasureContext, limitWidth float64, elements []InlineElement, bs BlockStyle) [][]InlineElement {
 	result := [][]InlineElement{{}}
 	rest := append([]InlineElement{}, elements...)
 
+	if !bs.WhiteSpace.wraps() {
+		for _, e := range rest {
+			if _, ok := e.(*LineBreakElement); ok {
+				result = append(result, []InlineElement{})
+			} else {
+				result[len(result)-1] = append(result[len(result)-1], e)
+			}
+		}
+		return result
+	}
+
 	lineWidth := 0.0
 	for len(rest) != 0 {
 		switch e := rest[0].(type) {
@@ -92,24 +184,39 @@ func wrapElements(mc MeasureContext, limitWidth float64, elements []InlineElemen
 			if e.Text == "" { // 幅0のテキストなら現在の行に追加
 				result[len(result)-1] = append(result[len(result)-1], e)
 				rest = rest[1:]
-			} else if ss := mc.GetSubText(e, limitWidth-lineWidth); ss == nil {
-				if lineWidth == 0 { // width == 0 なのにこれ以上入らないなら終了して無限ループを回避
-					// TODO 1文字入れる
-					return result
+			} else if ss := mc.GetSubText(e, limitWidth-lineWidth); ss == nil || breaksWord(ss.Text, e.Text) {
+				if lineWidth == 0 {
+					// The word does not fit even in an empty line, so break inside it
+					ss = breakWord(mc, e, limitWidth)
+					result[len(result)-1] = append(result[len(result)-1], ss)
+					lineWidth += mc.GetTextWidth(ss)
+					e2 := *e
+					e2.Text = strings.TrimPrefix(e.Text, ss.Text)
+					rest[0] = &e2
+					continue
 				}
 
 				// この行にこれ以上入らない
+				line, next := applyKinsoku(result[len(result)-1], e, bs.HangingPunctuation)
+				result[len(result)-1] = line
+				rest = append(next, rest[1:]...)
 				lineWidth = 0
 				result = append(result, []InlineElement{})
-				// Remove spaces immediately after line breaks
-				e.Text = strings.TrimPrefix(e.Text, " ")
+				// Spaces at a soft wrap hang at the end of the previous line
+				if e, ok := rest[0].(*TextElement); ok {
+					e2 := *e
+					e2.Text = strings.TrimLeft(e.Text, " ")
+					rest[0] = &e2
+				}
 			} else {
 				result[len(result)-1] = append(result[len(result)-1], ss)
 				lineWidth += mc.GetTextWidth(ss)
 				if ss.Text == e.Text {
 					rest = rest[1:]
 				} else {
-					rest[0] = &TextElement{Format: e.Format, Text: strings.TrimPrefix(e.Text, ss.Text)}
+					e2 := *e
+					e2.Text = strings.TrimPrefix(e.Text, ss.Text)
+					rest[0] = &e2
 				}
 			}
 
@@ -140,6 +247,234 @@ func wrapElements(mc MeasureContext, limitWidth float64, elements []InlineElemen
 	return result
 }
 
+// wordBreakChars are the characters after which a word that is too long is preferably broken, mainly for URLs.
+const wordBreakChars = "/-_?"
+
+// breaksWord reports whether splitting text into the leading part and the rest breaks inside a word.
+func breaksWord(leading, text string) bool {
+	if leading == "" || len(leading) == len(text) {
+		return false
+	}
+	before, _ := utf8.DecodeLastRuneInString(leading)
+	after, _ := utf8.DecodeRuneInString(text[len(leading):])
+	return !isBreakable(before) && !isBreakable(after)
+}
+
+// isBreakable reports whether a line can be broken before or after the character without breaking a word.
+func isBreakable(c rune) bool {
+	return unicode.IsSpace(c) || isCJK(c)
+}
+
+func isCJK(c rune) bool {
+	return unicode.In(c, unicode.Han, unicode.Hiragana, unicode.Katakana)
+}
+
+// splitLineBreaks splits the elements at line breaks, which are removed.
+func splitLineBreaks(elements []InlineElement) [][]InlineElement {
+	result := [][]InlineElement{{}}
+	for _, e := range elements {
+		if _, ok := e.(*LineBreakElement); ok {
+			result = append(result, []InlineElement{})
+		} else {
+			result[len(result)-1] = append(result[len(result)-1], e)
+		}
+	}
+	return result
+}
+
+// justifyLine returns the line stretched so that its text, without the white space at its end, fills the width.
+// The extra width is distributed equally to its gaps.
+// A gap is a space, or the boundary next to a CJK character with TextJustifyInterCharacter.
+// Texts are split at the gaps and a spacerElement is inserted into each gap.
+// If the line has no gaps or does not fall short of the width, it is returned as is.
+func justifyLine(mc MeasureContext, line []InlineElement, width float64, justify TextJustify) []InlineElement {
+	lineWidth, _ := getLineSize(mc, line)
+	extra := width - (lineWidth - trailingSpaceWidth(mc, line))
+	if extra <= 0 {
+		return line
+	}
+
+	pieces := []InlineElement{}
+	gaps := map[int]bool{} // indexes of the pieces followed by a gap
+
+	for k, e := range line {
+		t, ok := e.(*TextElement)
+		if !ok {
+			pieces = append(pieces, e)
+			continue
+		}
+
+		var following rune // the first character after the text on the line, or 0
+		if k+1 < len(line) {
+			if next, ok := line[k+1].(*TextElement); ok && next.Text != "" {
+				following, _ = utf8.DecodeRuneInString(next.Text)
+			}
+		}
+
+		runes := []rune(t.Text)
+		start := 0
+		for i, c := range runes {
+			next := following
+			if i+1 < len(runes) {
+				next = runes[i+1]
+			}
+
+			gap := c == ' '
+			if justify == TextJustifyInterCharacter && next != 0 && !unicode.IsSpace(next) && (isCJK(c) || isCJK(next)) {
+				gap = true
+			}
+			if gap {
+				e2 := *t
+				e2.Text = string(runes[start : i+1])
+				pieces = append(pieces, &e2)
+				gaps[len(pieces)-1] = true
+				start = i + 1
+			}
+		}
+		if start < len(runes) || len(runes) == 0 {
+			e2 := *t
+			e2.Text = string(runes[start:])
+			pieces = append(pieces, &e2)
+		}
+	}
+
+	// Spaces at the end of the line are not stretched
+	for i := len(pieces) - 1; i >= 0; i-- {
+		if e, ok := pieces[i].(*TextElement); ok && strings.TrimSpace(e.Text) == "" {
+			delete(gaps, i)
+			continue
+		}
+		delete(gaps, i)
+		break
+	}
+	if len(gaps) == 0 {
+		return line
+	}
+
+	result := []InlineElement{}
+	for i, e := range pieces {
+		result = append(result, e)
+		if gaps[i] {
+			result = append(result, &spacerElement{width: extra / float64(len(gaps))})
+		}
+	}
+	return result
+}
+
+// trailingSpaceWidth returns the width of the white space at the end of the line,
+// which may span several elements when the line is broken at the boundary of elements.
+func trailingSpaceWidth(mc MeasureContext, line []InlineElement) float64 {
+	width := 0.0
+	for i := len(line) - 1; i >= 0; i-- {
+		t, ok := line[i].(*TextElement)
+		if !ok {
+			break
+		}
+		trimmed := strings.TrimRightFunc(t.Text, unicode.IsSpace)
+		space := *t
+		space.Text = t.Text[len(trimmed):]
+		width += mc.GetTextWidth(&space)
+		if trimmed != "" {
+			break
+		}
+	}
+	return width
+}
+
+// breakWord returns the longest leading part of the text that fits in limitWidth.
+// The part ends after the last of wordBreakChars in it if any.
+// At least one character is returned so that the text is never lost even if limitWidth is too small.
+func breakWord(mc MeasureContext, e *TextElement, limitWidth float64) *TextElement {
+	runes := []rune(e.Text)
+	e2 := *e
+
+	n := 1
+	for ; n < len(runes); n++ {
+		e2.Text = string(runes[:n+1])
+		if mc.GetTextWidth(&e2) > limitWidth {
+			break
+		}
+	}
+	for i := n; i > 0; i-- {
+		if i < len(runes) && strings.ContainsRune(wordBreakChars, runes[i-1]) {
+			n = i
+			break
+		}
+	}
+
+	e2.Text = string(runes[:n])
+	return &e2
+}
+
+// applyWhiteSpace processes the white space in the text of the elements according to bs.WhiteSpace.
+// Preserved newlines become line breaks and preserved tabs are expanded to the next tab stop.
+// Otherwise, sequences of spaces, tabs and newlines are collapsed into a single space
+// and white space at the beginning of a line is removed.
+func applyWhiteSpace(elements []InlineElement, bs BlockStyle) []InlineElement {
+	tabSize := bs.TabSize
+	if tabSize <= 0 {
+		tabSize = 4
+	}
+
+	result := []InlineElement{}
+	lineStart := true // collapsed mode: the last character is a space or the line has no content yet
+	column := 0       // preserved mode: the number of characters since the last line break
+	for _, e := range elements {
+		switch e := e.(type) {
+		case *TextElement:
+			if bs.WhiteSpace.preserves() {
+				for i, text := range strings.Split(e.Text, "\n") {
+					if i != 0 {
+						result = append(result, &LineBreakElement{Format: e.Format})
+						column = 0
+					}
+					sb := strings.Builder{}
+					for _, c := range text {
+						if c == '\t' {
+							n := tabSize - column%tabSize
+							sb.WriteString(strings.Repeat(" ", n))
+							column += n
+						} else {
+							sb.WriteRune(c)
+							column++
+						}
+					}
+					if i == 0 || sb.Len() != 0 {
+						e2 := *e
+						e2.Text = sb.String()
+						result = append(result, &e2)
+					}
+				}
+			} else {
+				sb := strings.Builder{}
+				for _, c := range e.Text {
+					if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+						if !lineStart {
+							sb.WriteRune(' ')
+						}
+						lineStart = true
+					} else {
+						sb.WriteRune(c)
+						lineStart = false
+					}
+				}
+				e2 := *e
+				e2.Text = sb.String()
+				result = append(result, &e2)
+			}
+		case *LineBreakElement:
+			result = append(result, e)
+			lineStart = true
+			column = 0
+		default:
+			result = append(result, e)
+			lineStart = false
+			column++
+		}
+	}
+	return result
+}
+
 // TODO リネーム
 func GetNaturalWidth(mc MeasureContext, elements []InlineElement) float64 {
 	var lineWidth, maxWidth float64
@@ -159,3 +494,80 @@ func GetNaturalWidth(mc MeasureContext, elements []InlineElement) float64 {
 	}
 	return maxWidth
 }
+
+// GetMinContentWidth returns the width of the longest run of the elements that cannot be broken into lines,
+// that is the narrowest width the elements can be wrapped to without breaking inside a word.
+// The elements can be broken at spaces, around CJK characters and at line breaks.
+func GetMinContentWidth(mc MeasureContext, elements []InlineElement) float64 {
+	var runWidth, maxWidth float64
+	endRun := func() {
+		maxWidth = math.Max(maxWidth, runWidth)
+		runWidth = 0
+	}
+
+	for _, element := range elements {
+		switch e := element.(type) {
+		case *LineBreakElement:
+			endRun()
+		case *TextElement:
+			e2 := *e
+			word := []rune{}
+			addWord := func() {
+				e2.Text = string(word)
+				runWidth += mc.GetTextWidth(&e2)
+				word = word[:0]
+			}
+
+			for _, c := range e.Text {
+				switch {
+				case unicode.IsSpace(c):
+					addWord()
+					endRun()
+				case isCJK(c):
+					addWord()
+					endRun()
+					word = append(word, c)
+					addWord()
+					endRun()
+				default:
+					word = append(word, c)
+				}
+			}
+			addWord() // the run may continue into the next element
+		default:
+			w, _ := element.size(mc)
+			runWidth += w
+		}
+	}
+	endRun()
+	return maxWidth
+}
+
+// clipElements returns the leading elements of a line that fit in limitWidth.
+// A text that does not fit entirely is cut at a character boundary.
+func clipElements(mc MeasureContext, limitWidth float64, line []InlineElement) []InlineElement {
+	result := []InlineElement{}
+	lineWidth := 0.0
+	for _, e := range line {
+		w, _ := e.size(mc)
+		if lineWidth+w <= limitWidth {
+			result = append(result, e)
+			lineWidth += w
+			continue
+		}
+
+		if e, ok := e.(*TextElement); ok {
+			runes := []rune(e.Text)
+			for n := len(runes) - 1; n > 0; n-- {
+				e2 := *e
+				e2.Text = string(runes[:n])
+				if lineWidth+mc.GetTextWidth(&e2) <= limitWidth {
+					result = append(result, &e2)
+					break
+				}
+			}
+		}
+		break
+	}
+	return result
+}
@@ -3,6 +3,8 @@ package goldpdf
 import (
 	"fmt"
 	"image/color"
+	"math"
+	"strings"
 	"testing"
 
 	"github.com/jung-kurt/gofpdf"
@@ -18,7 +20,7 @@ func TestWrapElements(t *testing.T) {
 	}
 
 	result := ""
-	for _, line := range wrapElements(mc, 200, []InlineElement{text}) {
+	for _, line := range wrapElements(mc, 200, []InlineElement{text}, BlockStyle{}) {
 		result += fmt.Sprintf("%s\n", line)
 	}
 
@@ -39,3 +41,273 @@ func TestWrapElements(t *testing.T) {
 		t.Errorf("WrapElements() = %v, want %v", result, expected)
 	}
 }
+
+func TestWrapElementsKeepsLink(t *testing.T) {
+	fpdf := gofpdf.New("P", "pt", "A4", "")
+	mc := &renderContextImpl{fpdf: fpdf}
+
+	text := &TextElement{
+		Format: TextFormat{FontSize: 10, FontFamily: "Arial", Color: color.Black},
+		Text:   "Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor incididunt ut labore et dolore magna aliqua.",
+		Link:   "https://example.com/",
+	}
+
+	lines := wrapElements(mc, 100, []InlineElement{text}, BlockStyle{})
+	if len(lines) < 2 {
+		t.Fatalf("wrapElements() returned %d lines, want at least 2", len(lines))
+	}
+	for _, line := range lines {
+		for _, e := range line {
+			if e, ok := e.(*TextElement); ok && e.Link != text.Link {
+				t.Errorf("fragment %q has link %q, want %q", e.Text, e.Link, text.Link)
+			}
+		}
+	}
+}
+
+func TestApplyWhiteSpace(t *testing.T) {
+	tf := TextFormat{FontSize: 10, FontFamily: "Arial", Color: color.Black}
+	elements := []InlineElement{
+		&TextElement{Format: tf, Text: "  foo \t bar\n"},
+		&TextElement{Format: tf, Text: " baz"},
+	}
+
+	tests := []struct {
+		whiteSpace WhiteSpace
+		expected   string
+	}{
+		{WhiteSpaceNormal, "[foo bar  baz]"},
+		{WhiteSpaceNoWrap, "[foo bar  baz]"},
+		{WhiteSpacePre, "[  foo    bar \\n  baz]"},
+		{WhiteSpacePreWrap, "[  foo    bar \\n  baz]"},
+	}
+	for _, test := range tests {
+		result := applyWhiteSpace(elements, BlockStyle{WhiteSpace: test.whiteSpace, TabSize: 4})
+		if s := fmt.Sprint(result); s != test.expected {
+			t.Errorf("applyWhiteSpace(%v) = %q, want %q", test.whiteSpace, s, test.expected)
+		}
+	}
+}
+
+func TestWrapElementsBreaksLongWord(t *testing.T) {
+	fpdf := gofpdf.New("P", "pt", "A4", "")
+	mc := &renderContextImpl{fpdf: fpdf}
+
+	url := "https://api.example.com/v1/organizations/members/permissions?include_inherited=true"
+	text := &TextElement{
+		Format: TextFormat{FontSize: 10, FontFamily: "Arial", Color: color.Black},
+		Text:   "See " + url + " for details.",
+	}
+
+	for _, width := range []float64{100, 5} {
+		result := ""
+		for _, line := range wrapElements(mc, width, []InlineElement{text}, BlockStyle{}) {
+			if len(line) == 0 {
+				t.Fatalf("wrapElements(width=%v) returned an empty line", width)
+			}
+			for _, e := range line {
+				result += e.String()
+			}
+			result += " "
+		}
+		if width == 100 {
+			expected := "See https:// api.example.com/v1/ organizations/ members/ permissions?include_ inherited=true for details. "
+			if result != expected {
+				t.Errorf("wrapElements(width=%v) = %q, want %q", width, result, expected)
+			}
+		}
+		if stripped := strings.ReplaceAll(result, " ", ""); stripped != strings.ReplaceAll(text.Text, " ", "") {
+			t.Errorf("wrapElements(width=%v) lost text: %q", width, result)
+		}
+	}
+}
+
+func TestJustifyLine(t *testing.T) {
+	fpdf := gofpdf.New("P", "pt", "A4", "")
+	mc := &renderContextImpl{fpdf: fpdf}
+
+	tf := TextFormat{FontSize: 10, FontFamily: "Arial", Color: color.Black}
+	bold := tf
+	bold.Bold = true
+	line := []InlineElement{
+		&TextElement{Format: tf, Text: "foo bar "},
+		&TextElement{Format: bold, Text: "baz"},
+		&TextElement{Format: tf, Text: " qux "},
+	}
+
+	// The text without the space at the end of the line fills the width
+	visibleWidth := func(line []InlineElement) float64 {
+		w, _ := getLineSize(mc, line)
+		return w - trailingSpaceWidth(mc, line)
+	}
+	width := visibleWidth(line) + 30
+	justified := justifyLine(mc, line, width, TextJustifyInterWord)
+	if got := visibleWidth(justified); math.Abs(got-width) > 1e-9 {
+		t.Errorf("width of justified line = %v, want %v", got, width)
+	}
+	if s := fmt.Sprint(justified); s != "[foo   bar   baz    qux ]" {
+		t.Errorf("justifyLine() = %q", s)
+	}
+
+	// The space at the end of the line may span several elements
+	space := mc.GetTextWidth(&TextElement{Format: tf, Text: " "})
+	boldSpace := mc.GetTextWidth(&TextElement{Format: bold, Text: " "})
+	trailing := []InlineElement{&TextElement{Format: tf, Text: "foo  "}, &TextElement{Format: bold, Text: " "}, &TextElement{Format: tf}}
+	if got := trailingSpaceWidth(mc, trailing); math.Abs(got-(2*space+boldSpace)) > 1e-9 {
+		t.Errorf("trailingSpaceWidth() = %v, want %v", got, 2*space+boldSpace)
+	}
+
+	word := []InlineElement{&TextElement{Format: tf, Text: "word"}}
+	if justified := justifyLine(mc, word, visibleWidth(word)+30, TextJustifyInterCharacter); len(justified) != 1 {
+		t.Errorf("justifyLine() split a line without gaps: %v", justified)
+	}
+
+	// An inline image is kept in the line
+	image := &ImageElement{Width: 20, Height: 10}
+	line = []InlineElement{&TextElement{Format: tf, Text: "foo "}, image, &TextElement{Format: tf, Text: " bar"}}
+	width = visibleWidth(line) + 30
+	justified = justifyLine(mc, line, width, TextJustifyInterWord)
+	if got := visibleWidth(justified); math.Abs(got-width) > 1e-9 {
+		t.Errorf("width of justified line with an image = %v, want %v", got, width)
+	}
+	images := 0
+	for _, e := range justified {
+		if e == InlineElement(image) {
+			images++
+		}
+	}
+	if images != 1 {
+		t.Errorf("justifyLine() returned the image %d times, want 1", images)
+	}
+}
+
+func TestLineBreakerOptimal(t *testing.T) {
+	fpdf := gofpdf.New("P", "pt", "A4", "")
+	mc := &renderContextImpl{fpdf: fpdf}
+
+	text := &TextElement{
+		Format: TextFormat{FontSize: 10, FontFamily: "Arial", Color: color.Black},
+		Text:   "Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor incididunt ut labore et dolore magna aliqua. Ut enim ad minim veniam, quis nostrud exercitation ullamco laboris nisi ut aliquip ex ea commodo consequat.",
+	}
+
+	// looseness is the largest slack of the lines except the last
+	looseness := func(lines [][]InlineElement) float64 {
+		max := 0.0
+		for _, line := range lines[:len(lines)-1] {
+			w, _ := getLineSize(mc, line)
+			max = math.Max(max, 120-w)
+		}
+		return max
+	}
+
+	greedy := LineBreakerGreedy(mc, 120, []InlineElement{text}, BlockStyle{})
+	optimal := LineBreakerOptimal(mc, 120, []InlineElement{text}, BlockStyle{})
+
+	result := ""
+	for _, line := range optimal {
+		if w, _ := getLineSize(mc, line); w > 120 {
+			t.Errorf("line %v is wider than the limit: %v", line, w)
+		}
+		for _, e := range line {
+			result += e.String()
+		}
+		result += " "
+	}
+	if strings.TrimSpace(result) != text.Text {
+		t.Errorf("LineBreakerOptimal() lost text: %q", result)
+	}
+	if looseness(optimal) > looseness(greedy) {
+		t.Errorf("looseness of LineBreakerOptimal() = %v, want <= %v", looseness(optimal), looseness(greedy))
+	}
+
+	// An inline image is kept as an item of its own
+	image := &ImageElement{Width: 20, Height: 10}
+	images := 0
+	for _, line := range LineBreakerOptimal(mc, 120, []InlineElement{text, image, text}, BlockStyle{}) {
+		for _, e := range line {
+			if e == InlineElement(image) {
+				images++
+			}
+		}
+	}
+	if images != 1 {
+		t.Errorf("LineBreakerOptimal() returned the image %d times, want 1", images)
+	}
+}
+
+func TestApplyKinsoku(t *testing.T) {
+	tf := TextFormat{FontSize: 10, FontFamily: "Arial", Color: color.Black}
+
+	tests := []struct {
+		line, next string
+		hanging    bool
+		expected   string
+	}{
+		{"吾輩は猫で", "ある。名前", false, "[吾輩は猫で] [ある。名前]"},
+		{"吾輩は猫である", "。名前", false, "[吾輩は猫であ] [る 。名前]"},
+		{"吾輩は猫である", "。名前", true, "[吾輩は猫である 。] [名前]"},
+		{"ちょっと待", "って", false, "[ちょっと] [待 って]"},
+		{"彼は「", "猫」と", false, "[彼は] [「 猫」と]"},
+		{"Hello", "。", false, "[Hell] [o 。]"},
+		{"。", "。", false, "[。] [。]"},
+	}
+	for _, test := range tests {
+		line, next := applyKinsoku([]InlineElement{&TextElement{Format: tf, Text: test.line}}, &TextElement{Format: tf, Text: test.next}, test.hanging)
+		if s := fmt.Sprint(line, " ", next); s != test.expected {
+			t.Errorf("applyKinsoku(%q, %q, %v) = %q, want %q", test.line, test.next, test.hanging, s, test.expected)
+		}
+	}
+}
+
+func TestGetLineSizeAlignsBaselines(t *testing.T) {
+	fpdf := gofpdf.New("P", "pt", "A4", "")
+	mc := &renderContextImpl{fpdf: fpdf}
+
+	tf := TextFormat{FontSize: 10, FontFamily: "Arial", Color: color.Black}
+	large := tf
+	large.FontSize = 20
+	spaced := tf
+	spaced.LineHeight = 3
+	superscript := tf
+	superscript.BaselineShift = 4
+
+	tests := []struct {
+		line             []InlineElement
+		height, baseline float64
+	}{
+		{[]InlineElement{&TextElement{Format: tf, Text: "a"}}, 10, 8},
+		{[]InlineElement{&TextElement{Format: tf, Text: "a"}, &TextElement{Format: large, Text: "b"}}, 20, 16},
+		{[]InlineElement{&TextElement{Format: spaced, Text: "a"}}, 30, 18},
+		{[]InlineElement{&TextElement{Format: tf, Text: "a"}, &TextElement{Format: superscript, Text: "1"}}, 14, 12},
+		{[]InlineElement{&TextElement{Format: tf, Text: "a"}, &ImageElement{Width: 5, Height: 30}}, 32, 30},
+	}
+	for i, test := range tests {
+		_, height := getLineSize(mc, test.line)
+		baseline := getLineBaseline(mc, test.line)
+		if math.Abs(height-test.height) > 1e-9 || math.Abs(baseline-test.baseline) > 1e-9 {
+			t.Errorf("#%d: height, baseline = %v, %v, want %v, %v", i, height, baseline, test.height, test.baseline)
+		}
+	}
+}
+
+func TestGetMinContentWidth(t *testing.T) {
+	fpdf := gofpdf.New("P", "pt", "A4", "")
+	mc := &renderContextImpl{fpdf: fpdf}
+	tf := TextFormat{FontSize: 10, FontFamily: "Arial", Color: color.Black}
+	width := func(text string) float64 { return mc.GetTextWidth(&TextElement{Format: tf, Text: text}) }
+
+	tests := []struct {
+		elements []InlineElement
+		want     float64
+	}{
+		{[]InlineElement{&TextElement{Format: tf, Text: "a longest word"}}, width("longest")},
+		// A word formatted in parts is not broken between them
+		{[]InlineElement{&TextElement{Format: tf, Text: "x foo"}, &TextElement{Format: tf, Text: "barbaz y"}}, width("foobarbaz")},
+		{[]InlineElement{&TextElement{Format: tf, Text: "ab"}, &LineBreakElement{Format: tf}, &TextElement{Format: tf, Text: "cd"}}, width("ab")},
+	}
+	for _, tt := range tests {
+		if got := GetMinContentWidth(mc, tt.elements); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("GetMinContentWidth(%v) = %v, want %v", tt.elements, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package goldpdf
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/jung-kurt/gofpdf"
+	"golang.org/x/image/font/sfnt"
+)
+
+// coreFontFamilies are the families of the standard PDF fonts, which are available without registration.
+// Since goldpdf writes the text of these fonts as UTF-8, only ASCII characters are drawn correctly.
+var coreFontFamilies = map[string]bool{
+	"arial": true, "helvetica": true, "courier": true, "times": true, "symbol": true, "zapfdingbats": true,
+}
+
+// WithFontCoverage registers the TrueType data of a font family that the PDFProvider adds to the PDF,
+// so that the characters missing from it can be drawn with TextFormat.FallbackFontFamilies.
+// A family without registered data is assumed to have every character, except for the core fonts.
+func WithFontCoverage(family string, ttf []byte) Option {
+	return func(r *Renderer) {
+		if r.fontData == nil {
+			r.fontData = map[string][]byte{}
+		}
+		r.fontData[strings.ToLower(family)] = ttf
+	}
+}
+
+// loadFonts parses the registered font data.
+func (r *Renderer) loadFonts() error {
+	if r.fonts != nil {
+		return nil
+	}
+	r.fonts = map[string]*sfnt.Font{}
+	for family, data := range r.fontData {
+		f, err := sfnt.Parse(data)
+		if err != nil {
+			return fmt.Errorf("font family %q: %w", family, err)
+		}
+		r.fonts[family] = f
+	}
+	return nil
+}
+
+// hasGlyph reports whether the font family has a glyph for the character.
+func (r *Renderer) hasGlyph(family string, c rune) bool {
+	family = strings.ToLower(family)
+	if f, ok := r.fonts[family]; ok {
+		index, err := f.GlyphIndex(&r.sfntBuffer, c)
+		return err == nil && index != 0
+	}
+	if coreFontFamilies[family] {
+		return c < unicode.MaxASCII
+	}
+	return true
+}
+
+// applyFontFallback splits the texts into runs drawn with the first font family having the glyphs of the run,
+// among TextFormat.FontFamily and TextFormat.FallbackFontFamilies.
+// White space stays in the run of the preceding character to avoid splitting texts needlessly.
+func (r *Renderer) applyFontFallback(elements []InlineElement) []InlineElement {
+	result := []InlineElement{}
+	for _, e := range elements {
+		t, ok := e.(*TextElement)
+		if !ok || len(t.Format.FallbackFontFamilies) == 0 {
+			result = append(result, e)
+			continue
+		}
+
+		families := append([]string{t.Format.FontFamily}, t.Format.FallbackFontFamilies...)
+		runStart, runFamily := 0, ""
+		flush := func(end int) {
+			if end > runStart {
+				t2 := *t
+				t2.Format.FontFamily = runFamily
+				t2.Text = t.Text[runStart:end]
+				result = append(result, &t2)
+			}
+			runStart = end
+		}
+
+		for i, c := range t.Text {
+			if unicode.IsSpace(c) && runFamily != "" && r.hasGlyph(runFamily, c) {
+				continue
+			}
+			family := families[0] // if no family has the glyph, the primary family draws it as missing
+			for _, f := range families {
+				if r.hasGlyph(f, c) {
+					family = f
+					break
+				}
+			}
+			if family != runFamily {
+				flush(i)
+				runFamily = family
+			}
+		}
+		flush(len(t.Text))
+
+		if len(t.Text) == 0 {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// FontSource provides the TrueType data of a font.
+type FontSource func() ([]byte, error)
+
+// FontBytes is a FontSource of the TrueType data in memory.
+func FontBytes(data []byte) FontSource {
+	return func() ([]byte, error) { return data, nil }
+}
+
+// FontFile is a FontSource of a TrueType file.
+func FontFile(path string) FontSource {
+	return func() ([]byte, error) { return os.ReadFile(path) }
+}
+
+// FontFS is a FontSource of a TrueType file in a file system such as embed.FS.
+func FontFS(fsys fs.FS, name string) FontSource {
+	return func() ([]byte, error) { return fs.ReadFile(fsys, name) }
+}
+
+// fontFamily is a font family registered with WithFontFamily.
+type fontFamily struct {
+	name    string
+	sources [4]FontSource // indexed by fontVariant
+}
+
+// fontVariant is an index of the variants of a font family.
+type fontVariant int
+
+const (
+	fontRegular fontVariant = iota
+	fontBold
+	fontItalic
+	fontBoldItalic
+)
+
+// fpdfStyle returns the style string of gofpdf for the variant.
+func (v fontVariant) fpdfStyle() string {
+	return []string{"", "B", "I", "BI"}[v]
+}
+
+// loadedFont is the data of a font variant added to the PDF and how it is synthesized if missing.
+type loadedFont struct {
+	data       []byte
+	fakeBold   bool
+	fakeItalic bool
+}
+
+// WithFontFamily registers a TrueType font family so that it can be used by the TextFormat.
+// bold, italic and boldItalic may be nil, in which case the variant is synthesized
+// by emboldening or slanting the glyphs of another variant.
+// The font data also determines the glyph coverage of the family for TextFormat.FallbackFontFamilies.
+// An error in loading the fonts is returned by NewRenderer, or by Render if the Renderer is created by New.
+func WithFontFamily(name string, regular, bold, italic, boldItalic FontSource) Option {
+	return func(r *Renderer) {
+		r.fontFamilies = append(r.fontFamilies, fontFamily{name: name, sources: [4]FontSource{regular, bold, italic, boldItalic}})
+	}
+}
+
+// loadFontFamilies loads the fonts of the families registered with WithFontFamily.
+func (r *Renderer) loadFontFamilies() error {
+	if r.loadedFonts != nil {
+		return nil
+	}
+
+	loadedFonts := map[string][4]loadedFont{}
+	for _, family := range r.fontFamilies {
+		if family.sources[fontRegular] == nil {
+			return fmt.Errorf("font family %q: the regular variant is required", family.name)
+		}
+
+		data := [4][]byte{}
+		for v, source := range family.sources {
+			if source == nil {
+				continue
+			}
+			d, err := source()
+			if err != nil {
+				return fmt.Errorf("font family %q: %w", family.name, err)
+			}
+			if _, err := sfnt.Parse(d); err != nil {
+				return fmt.Errorf("font family %q: %w", family.name, err)
+			}
+			data[v] = d
+		}
+
+		fonts := [4]loadedFont{}
+		fonts[fontRegular] = loadedFont{data: data[fontRegular]}
+		fonts[fontBold] = loadedFont{data: data[fontBold]}
+		if data[fontBold] == nil {
+			fonts[fontBold] = loadedFont{data: data[fontRegular], fakeBold: true}
+		}
+		fonts[fontItalic] = loadedFont{data: data[fontItalic]}
+		if data[fontItalic] == nil {
+			fonts[fontItalic] = loadedFont{data: data[fontRegular], fakeItalic: true}
+		}
+		switch {
+		case data[fontBoldItalic] != nil:
+			fonts[fontBoldItalic] = loadedFont{data: data[fontBoldItalic]}
+		case data[fontBold] != nil:
+			fonts[fontBoldItalic] = loadedFont{data: data[fontBold], fakeItalic: true}
+		case data[fontItalic] != nil:
+			fonts[fontBoldItalic] = loadedFont{data: data[fontItalic], fakeBold: true}
+		default:
+			fonts[fontBoldItalic] = loadedFont{data: data[fontRegular], fakeBold: true, fakeItalic: true}
+		}
+
+		key := strings.ToLower(family.name)
+		loadedFonts[key] = fonts
+		if _, ok := r.fontData[key]; !ok {
+			WithFontCoverage(family.name, data[fontRegular])(r)
+		}
+	}
+	r.loadedFonts = loadedFonts
+	return nil
+}
+
+// addFonts adds the fonts registered with WithFontFamily to the PDF.
+func (r *Renderer) addFonts(fpdf *gofpdf.Fpdf) {
+	for family, fonts := range r.loadedFonts {
+		for v, font := range fonts {
+			fpdf.AddUTF8FontFromBytes(family, fontVariant(v).fpdfStyle(), font.data)
+		}
+	}
+}
+
+// syntheticStyle reports whether the bold and italic of the text format are synthesized.
+func (r *Renderer) syntheticStyle(tf TextFormat) (bool, bool) {
+	fonts, ok := r.loadedFonts[strings.ToLower(tf.FontFamily)]
+	if !ok {
+		return false, false
+	}
+	v := fontRegular
+	if tf.Bold {
+		v |= fontBold
+	}
+	if tf.Italic {
+		v |= fontItalic
+	}
+	return fonts[v].fakeBold, fonts[v].fakeItalic
+}
+
+// checkFontFamilies checks that the font families referenced by the options are available in the PDF.
+// Only the families of DefaultStyler and PageNumberDecorator are known before rendering.
+func (r *Renderer) checkFontFamilies(fpdf *gofpdf.Fpdf) error {
+	families := []string{}
+	if s, ok := r.styler.(*DefaultStyler); ok {
+		families = append(families, s.FontFamily)
+		families = append(families, s.FallbackFontFamilies...)
+	}
+	if d, ok := r.pageDecorator.(*PageNumberDecorator); ok && d.Format.FontFamily != "" {
+		families = append(families, d.Format.FontFamily)
+	}
+
+	for _, family := range families {
+		if fpdf.SetFont(family, "", 12); fpdf.Err() {
+			return fmt.Errorf("font family %q is not available: %w", family, fpdf.Error())
+		}
+	}
+	return nil
+}
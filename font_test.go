@@ -0,0 +1,96 @@
+package goldpdf
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/text"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestApplyFontFallback(t *testing.T) {
+	r := New(WithFontCoverage("Go", goregular.TTF)).(*Renderer)
+	if err := r.loadFonts(); err != nil {
+		t.Fatal(err)
+	}
+
+	tf := TextFormat{FontSize: 10, FontFamily: "Arial", FallbackFontFamilies: []string{"Go"}, Color: color.Black}
+	result := ""
+	for _, e := range r.applyFontFallback([]InlineElement{&TextElement{Format: tf, Text: "Price: 5 €, Ελλάδα 日本"}}) {
+		e := e.(*TextElement)
+		result += fmt.Sprintf("[%s:%s]", e.Format.FontFamily, e.Text)
+	}
+
+	expected := "[Arial:Price: 5 ][Go:€][Arial:, ][Go:Ελλάδα ][Arial:日本]"
+	if result != expected {
+		t.Errorf("applyFontFallback() = %v, want %v", result, expected)
+	}
+}
+
+func TestWithFontFamily(t *testing.T) {
+	r, err := NewRenderer(
+		WithFontFamily("Go", FontBytes(goregular.TTF), nil, FontBytes(goitalic.TTF), nil),
+		WithStyler(&DefaultStyler{FontFamily: "Go", FallbackFontFamilies: []string{"Arial"}, FontSize: 12, Color: color.Black}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		bold, italic         bool
+		fakeBold, fakeItalic bool
+	}{
+		{false, false, false, false},
+		{true, false, true, false},
+		{false, true, false, false},
+		{true, true, true, false}, // synthesized from the italic
+	}
+	for _, test := range tests {
+		fakeBold, fakeItalic := r.syntheticStyle(TextFormat{FontFamily: "go", Bold: test.bold, Italic: test.italic})
+		if fakeBold != test.fakeBold || fakeItalic != test.fakeItalic {
+			t.Errorf("syntheticStyle(bold=%v, italic=%v) = %v, %v, want %v, %v", test.bold, test.italic, fakeBold, fakeItalic, test.fakeBold, test.fakeItalic)
+		}
+	}
+
+	invalidOptions := [][]Option{
+		{WithStyler(&DefaultStyler{FontFamily: "Missing", FontSize: 12, Color: color.Black})},
+		{WithStyler(&DefaultStyler{FontFamily: "Arial", FallbackFontFamilies: []string{"Missing"}, FontSize: 12, Color: color.Black})},
+		{WithFontFamily("Go", FontFile("testdata/missing.ttf"), nil, nil, nil)},
+		{WithFontFamily("Go", FontBytes([]byte("not a font")), nil, nil, nil)},
+		{WithFontFamily("Go", nil, FontBytes(goregular.TTF), nil, nil)},
+	}
+	for i, options := range invalidOptions {
+		if _, err := NewRenderer(options...); err == nil {
+			t.Errorf("NewRenderer() with invalid options #%d returned no error", i)
+		}
+	}
+}
+
+func TestWithFontFamilyRender(t *testing.T) {
+	styler := WithStyler(&DefaultStyler{FontFamily: "Go", FontSize: 12, Color: color.Black})
+	_, output := renderMarkdown(t, "**bold** and *italic*\n", WithFontFamily("Go", FontBytes(goregular.TTF), nil, FontBytes(goitalic.TTF), nil), styler)
+	if !strings.Contains(output, "/BaseFont /utf8go") {
+		t.Errorf("the font family is not embedded in the PDF")
+	}
+
+	// New does not call the PDFProvider, and the error in the options is returned by Render
+	calls := 0
+	r := New(styler, WithPDFProvider(func() *gofpdf.Fpdf {
+		calls++
+		return gofpdf.New(gofpdf.OrientationPortrait, "pt", "A4", ".")
+	}))
+	if calls != 0 {
+		t.Errorf("New() called the PDFProvider %d times, want 0", calls)
+	}
+	source := []byte("text\n")
+	doc := goldmark.New().Parser().Parse(text.NewReader(source))
+	if err := r.Render(&bytes.Buffer{}, source, doc); err == nil {
+		t.Errorf("Render() with a missing font family returned no error")
+	}
+}
@@ -0,0 +1,219 @@
+package goldpdf
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/yuin/goldmark/ast"
+	xast "github.com/yuin/goldmark/extension/ast"
+)
+
+// FootnotePlacement specifies where the footnotes of the Footnote extension are placed.
+type FootnotePlacement int
+
+const (
+	// FootnotesAsEndnotes places the footnotes at the end of the document.
+	FootnotesAsEndnotes FootnotePlacement = iota
+	// FootnotesAtPageBottom places each footnote at the bottom of the page where it is first referenced.
+	FootnotesAtPageBottom
+)
+
+// footnotePlacement is the page where the footnote is placed and the height it occupies, including its margins.
+type footnotePlacement struct {
+	page   int
+	height float64
+}
+
+// footnoteDestination returns the name of the link destination of the footnote.
+func footnoteDestination(index int) string {
+	return fmt.Sprintf("fn:%d", index)
+}
+
+// footnoteReferenceDestination returns the name of the link destination of the first reference to the footnote,
+// which the backlink of the footnote points to.
+func footnoteReferenceDestination(index int) string {
+	return fmt.Sprintf("fnref:%d", index)
+}
+
+// collectFootnotes collects the footnotes and the footnote list of the document.
+func (r *Renderer) collectFootnotes(doc ast.Node) error {
+	r.footnotes = map[int]*xast.Footnote{}
+	r.footnoteList = nil
+	return ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering {
+			switch n := n.(type) {
+			case *xast.FootnoteList:
+				r.footnoteList = n
+			case *xast.Footnote:
+				r.footnotes[n.Index] = n
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// reservedHeights returns the heights reserved at the top and bottom of the page
+// for the headers and footers drawn by the PageDecorator, for the table being rendered and for the footnotes.
+func (r *Renderer) reservedHeights(page int) (float64, float64) {
+	var header, footer float64
+	if r.pageDecorator != nil {
+		header, footer = r.pageDecorator.ReservedHeights(page)
+	}
+	if page > r.repeatedTableHeader.page {
+		header += r.repeatedTableHeader.height
+	}
+	if page > r.splitTableRow.page {
+		header += r.splitTableRow.top
+	}
+	if page >= r.splitTableRow.page {
+		footer += r.splitTableRow.bottom
+	}
+	return header, footer + r.footnoteAreaHeight(page, nil)
+}
+
+// footnoteAreaHeight returns the height of the footnote area at the bottom of the page
+// when the additional footnotes are placed on the page in addition to those already placed.
+func (r *Renderer) footnoteAreaHeight(page int, additional map[*xast.Footnote]float64) float64 {
+	height := 0.0
+	for fn, p := range r.footnotePlacements {
+		if _, ok := additional[fn]; !ok && p.page == page {
+			height += p.height
+		}
+	}
+	for _, h := range additional {
+		height += h
+	}
+	if height == 0 {
+		return 0
+	}
+
+	bs := r.blockStyle(r.footnoteList)
+	return height + top(bs.Margin) + top(bs.Border) + top(bs.Padding) + bottom(bs.Padding) + bottom(bs.Border)
+}
+
+// lineFootnotes returns the footnotes first referenced in the line if it is placed on the page,
+// along with their heights.
+func (r *Renderer) lineFootnotes(mc MeasureContext, line []InlineElement, page int) (map[*xast.Footnote]float64, error) {
+	if r.footnotePlacements == nil {
+		return nil, nil
+	}
+
+	footnotes := map[*xast.Footnote]float64{}
+	for _, e := range line {
+		e, ok := e.(*TextElement)
+		if !ok || e.footnote == nil {
+			continue
+		}
+		if p, ok := r.footnotePlacements[e.footnote]; ok && p.page < page {
+			continue // already placed by an earlier reference
+		}
+
+		marginTop, marginBottom := r.collapsedMargins(e.footnote)
+		rect, err := r.measureBlockNode(e.footnote, mc, r.footnoteBounds.Shrink(horizontalSpacing(r.blockStyle(e.footnote).Margin)))
+		if err != nil {
+			return nil, err
+		}
+		footnotes[e.footnote] = rect.Bottom.Position - rect.Top.Position + marginTop + marginBottom
+	}
+	return footnotes, nil
+}
+
+// placeFootnotes places the footnotes at the bottom of the page.
+func (r *Renderer) placeFootnotes(footnotes map[*xast.Footnote]float64, page int) {
+	for fn, height := range footnotes {
+		r.footnotePlacements[fn] = footnotePlacement{page: page, height: height}
+	}
+}
+
+// renderPageFootnotes draws the placed footnotes at the bottom of each page.
+func (r *Renderer) renderPageFootnotes(mc MeasureContext) error {
+	if len(r.footnotePlacements) == 0 {
+		return nil
+	}
+
+	areaHeights := map[int]float64{}
+	pageFootnotes := map[int][]*xast.Footnote{}
+	pages := []int{}
+	for fn, p := range r.footnotePlacements {
+		if _, ok := pageFootnotes[p.page]; !ok {
+			pages = append(pages, p.page)
+			areaHeights[p.page] = r.footnoteAreaHeight(p.page, nil)
+		}
+		pageFootnotes[p.page] = append(pageFootnotes[p.page], fn)
+	}
+	sort.Ints(pages)
+
+	// From here on the body bounds do not exclude the footnotes
+	r.footnotePlacements = nil
+
+	bs := r.blockStyle(r.footnoteList)
+	for _, page := range pages {
+		footnotes := pageFootnotes[page]
+		sort.Slice(footnotes, func(i, j int) bool { return footnotes[i].Index < footnotes[j].Index })
+
+		_, pageBottom := mc.GetPageVerticalBounds(page)
+		borderBox := r.footnoteBounds.Expand(bs.Border, bs.Padding)
+		borderBox.Top = VerticalCoord{Page: page, Position: pageBottom - areaHeights[page] + top(bs.Margin)}
+
+		err := mc.GetRenderContext(func(rc RenderContext) error {
+			rc.DrawBox(borderBox.ToRect(VerticalCoord{Page: page, Position: pageBottom}), bs.BackgroundColor, bs.Border)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		contentBox := borderBox.Shrink(bs.Border, bs.Padding)
+		for _, fn := range footnotes {
+			bs2 := r.blockStyle(fn)
+			marginTop, marginBottom := r.collapsedMargins(fn)
+			box := contentBox.Shrink(horizontalSpacing(bs2.Margin))
+			box.Top.Position += marginTop
+			rect, err := r.renderBlockNode(fn, mc, box)
+			if err != nil {
+				return err
+			}
+			contentBox.Top = rect.Bottom
+			contentBox.Top.Position += marginBottom
+		}
+	}
+	return nil
+}
+
+// renderFootnote draws a footnote with its number.
+func (r *Renderer) renderFootnote(n *xast.Footnote, mc MeasureContext, borderBox HalfBounds) (Rect, error) {
+	rect, err := r.renderGenericBlockNode(n, mc, borderBox)
+	if err != nil {
+		return Rect{}, err
+	}
+
+	err = mc.GetRenderContext(func(rc RenderContext) error {
+		if rc, ok := rc.(*renderContextImpl); ok {
+			rc.AddDestination(footnoteDestination(n.Index), rect.Top)
+		}
+
+		bs := r.blockStyle(n)
+		contentBox := borderBox.Shrink(bs.Border, bs.Padding)
+		ts := &TextElement{
+			Format: r.textFormat(n),
+			Text:   fmt.Sprintf("%d.", n.Index),
+		}
+		baseline := ts.Format.FontSize
+		if n2 := n.FirstChild(); n2 != nil {
+			bs2 := r.blockStyle(n2)
+			contentBox = r.firstChildBorderBox(n, contentBox).Shrink(bs2.Border, bs2.Padding)
+			var err error
+			if baseline, err = r.firstLineBaseline(n2, rc, contentBox); err != nil {
+				return err
+			}
+		}
+
+		rc.DrawText(contentBox.Top.Page, contentBox.Left-rc.GetTextWidth(ts)-4, contentBox.Top.Position+baseline-ts.Format.FontSize, ts)
+		return nil
+	})
+	if err != nil {
+		return Rect{}, err
+	}
+
+	return rect, nil
+}
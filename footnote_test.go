@@ -0,0 +1,37 @@
+package goldpdf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFootnotePlacement(t *testing.T) {
+	filler := strings.Repeat("filler\n\n", 60)
+	tests := []struct {
+		name      string
+		source    string
+		placement FootnotePlacement
+		wantPage  int
+	}{
+		{"as endnotes", "text[^1]\n\n" + filler + "[^1]: Note\n", FootnotesAsEndnotes, 2},
+		{"at page bottom", "text[^1]\n\n" + filler + "[^1]: Note\n", FootnotesAtPageBottom, 1},
+		{"referenced on the next page", filler + "> " + strings.Repeat("long quoted text ", 200) + "end[^1]\n\n[^1]: Note\n", FootnotesAtPageBottom, 3},
+	}
+	for _, tt := range tests {
+		_, output := renderMarkdown(t, tt.source, WithFootnotePlacement(tt.placement))
+		page := 0
+		for i, contents := range pageContents(output) {
+			if strings.Contains(contents, "(Note)") {
+				page = i + 1
+			}
+		}
+		if page != tt.wantPage {
+			t.Errorf("%s: the footnote is drawn on page %d, want %d", tt.name, page, tt.wantPage)
+		}
+
+		// The reference links to the footnote and the backlink of the footnote links back to the reference
+		if got := strings.Count(output, "/Dest ["); got != 2 {
+			t.Errorf("%s: %d internal links are written, want 2", tt.name, got)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// Package highlight provides a goldpdf.Highlighter using github.com/alecthomas/chroma,
+// kept out of the goldpdf package so that chroma is only needed by the programs using it.
+package highlight
+
+import (
+	"image/color"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/psyark/goldpdf"
+)
+
+var (
+	_ goldpdf.Highlighter = &Chroma{}
+)
+
+// Chroma is a goldpdf.Highlighter using github.com/alecthomas/chroma.
+type Chroma struct {
+	// Style is the name of the chroma style such as "github" or "monokai".
+	// The default is "github".
+	Style string
+}
+
+func (h *Chroma) Highlight(language string, code string, tf goldpdf.TextFormat) ([]goldpdf.InlineElement, error) {
+	lexer := lexers.Get(language)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	styleName := h.Style
+	if styleName == "" {
+		styleName = "github"
+	}
+	style := styles.Get(styleName)
+
+	iterator, err := lexer.Tokenise(nil, code)
+	if err != nil {
+		return nil, err
+	}
+
+	elements := []goldpdf.InlineElement{}
+	for _, token := range iterator.Tokens() {
+		entry := style.Get(token.Type)
+
+		tf2 := tf
+		if entry.Colour.IsSet() {
+			tf2.Color = color.RGBA{R: entry.Colour.Red(), G: entry.Colour.Green(), B: entry.Colour.Blue(), A: 0xFF}
+		}
+		tf2.Bold = tf.Bold || entry.Bold == chroma.Yes
+		tf2.Italic = tf.Italic || entry.Italic == chroma.Yes
+		tf2.Underline = tf.Underline || entry.Underline == chroma.Yes
+
+		for i, text := range strings.Split(token.Value, "\n") {
+			if i != 0 {
+				elements = append(elements, &goldpdf.LineBreakElement{Format: tf})
+			}
+			if text != "" {
+				elements = append(elements, &goldpdf.TextElement{Format: tf2, Text: text})
+			}
+		}
+	}
+	return elements, nil
+}
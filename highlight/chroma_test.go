@@ -0,0 +1,37 @@
+package highlight
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/psyark/goldpdf"
+)
+
+func TestChroma(t *testing.T) {
+	tf := goldpdf.TextFormat{FontFamily: "Courier", FontSize: 10, Color: color.Black}
+	elements, err := (&Chroma{}).Highlight("go", "package main\n\nfunc main() {}\n", tf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text, colored, lineBreaks := "", false, 0
+	for _, e := range elements {
+		switch e := e.(type) {
+		case *goldpdf.TextElement:
+			text += e.Text
+			colored = colored || e.Format.Color != tf.Color
+		case *goldpdf.LineBreakElement:
+			text += "\n"
+			lineBreaks++
+		}
+	}
+	if text != "package main\n\nfunc main() {}\n" {
+		t.Errorf("the elements have the text %q", text)
+	}
+	if lineBreaks != 3 {
+		t.Errorf("the elements have %d line breaks, want 3", lineBreaks)
+	}
+	if !colored {
+		t.Errorf("no token of the Go code is colored")
+	}
+}
@@ -0,0 +1,10 @@
+package goldpdf
+
+// Highlighter splits the code of a fenced code block into inline elements formatted for each token.
+// The highlight package provides one using github.com/alecthomas/chroma.
+type Highlighter interface {
+	// Highlight returns the elements of the code with a LineBreakElement at the end of each line.
+	// language is the language of the info string of the fenced code block and may be empty.
+	// tf is the TextFormat of the code block.
+	Highlight(language string, code string, tf TextFormat) ([]InlineElement, error)
+}
@@ -0,0 +1,99 @@
+package goldpdf
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// kinsokuLineStart are the characters that must not start a line:
+	// closing brackets, punctuation, small kana, iteration marks and long vowel marks.
+	kinsokuLineStart = ")]}）〕］｝〉》」』】〙〗〟’”｠»" +
+		"、。，．,.:;!?！？：；・" +
+		"ぁぃぅぇぉっゃゅょゎゕゖァィゥェォッャュョヮヵヶㇰㇱㇲㇳㇴㇵㇶㇷㇸㇹㇺㇻㇼㇽㇾㇿ" +
+		"ゝゞヽヾ々〻ー‐゠–〜～"
+	// kinsokuLineEnd are the characters that must not end a line: opening brackets.
+	kinsokuLineEnd = "([{（〔［｛〈《「『【〘〖〝‘“｟«"
+	// kinsokuHangable are the characters that may hang beyond the end of a line (burasage).
+	kinsokuHangable = "、。，．,."
+	// maxKinsokuPush is the maximum number of characters pushed out to the next line for a single break.
+	maxKinsokuPush = 4
+)
+
+// violatesKinsoku reports whether breaking a line between the characters violates the Japanese line breaking rules.
+func violatesKinsoku(before, after rune) bool {
+	return strings.ContainsRune(kinsokuLineStart, after) || strings.ContainsRune(kinsokuLineEnd, before)
+}
+
+// applyKinsoku adjusts a soft wrap between a line and the next text
+// according to the Japanese line breaking rules (kinsoku shori).
+// If hanging is true, a punctuation mark that must not start a line is hung at the end of the line.
+// Otherwise characters are pushed out from the end of the line to the next line.
+// It returns the adjusted line and the elements that start the next line.
+func applyKinsoku(line []InlineElement, next *TextElement, hanging bool) ([]InlineElement, []InlineElement) {
+	line = append([]InlineElement{}, line...)
+	nexts := []InlineElement{next}
+
+	if after := firstRune(nexts); hanging && strings.ContainsRune(kinsokuHangable, after) && !unicode.IsSpace(lastRune(line)) {
+		hung, rest := *next, *next
+		hung.Text = string(after)
+		rest.Text = next.Text[utf8.RuneLen(after):]
+		line = append(line, &hung)
+		nexts[0] = &rest
+	}
+
+	for i := 0; i < maxKinsokuPush; i++ {
+		before, after := lastRune(line), firstRune(nexts)
+		if before == 0 || after == 0 || unicode.IsSpace(before) || unicode.IsSpace(after) || !violatesKinsoku(before, after) {
+			break
+		}
+
+		last, ok := line[len(line)-1].(*TextElement)
+		if !ok || len(line) == 1 && utf8.RuneCountInString(last.Text) <= 1 {
+			break // the line would become empty
+		}
+
+		kept, pushed := *last, *last
+		kept.Text = last.Text[:len(last.Text)-utf8.RuneLen(before)]
+		pushed.Text = string(before)
+		if kept.Text == "" {
+			line = line[:len(line)-1]
+		} else {
+			line[len(line)-1] = &kept
+		}
+		nexts = append([]InlineElement{&pushed}, nexts...)
+	}
+
+	return line, nexts
+}
+
+// firstRune returns the first character of the elements, or 0 if they do not start with a text.
+func firstRune(elements []InlineElement) rune {
+	for _, e := range elements {
+		t, ok := e.(*TextElement)
+		if !ok {
+			return 0
+		}
+		if t.Text != "" {
+			r, _ := utf8.DecodeRuneInString(t.Text)
+			return r
+		}
+	}
+	return 0
+}
+
+// lastRune returns the last character of the elements, or 0 if they do not end with a text.
+func lastRune(elements []InlineElement) rune {
+	for i := len(elements) - 1; i >= 0; i-- {
+		t, ok := elements[i].(*TextElement)
+		if !ok {
+			return 0
+		}
+		if t.Text != "" {
+			r, _ := utf8.DecodeLastRuneInString(t.Text)
+			return r
+		}
+	}
+	return 0
+}
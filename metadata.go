@@ -0,0 +1,110 @@
+package goldpdf
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/yuin/goldmark/ast"
+)
+
+// Metadata is the document information of the PDF.
+// Empty fields are not written.
+type Metadata struct {
+	Title        string
+	Author       string
+	Subject      string
+	Keywords     []string
+	Creator      string
+	CreationDate time.Time
+}
+
+// metadataDateLayouts are the layouts accepted for a date written as a string in the front matter.
+var metadataDateLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"}
+
+// merge overwrites the fields of m with the values found in the front matter of the document.
+// The front matter is available when the document is parsed by goldmark-meta with meta.WithStoresInDocument().
+func (m Metadata) merge(doc ast.Node) Metadata {
+	d, ok := doc.(*ast.Document)
+	if !ok {
+		return m
+	}
+
+	for key, value := range d.Meta() {
+		if value == nil {
+			continue // a key without a value such as "title:" leaves the field as it is
+		}
+		switch strings.ToLower(key) {
+		case "title":
+			m.Title = metadataString(value)
+		case "author", "authors":
+			m.Author = metadataString(value)
+		case "subject", "description":
+			m.Subject = metadataString(value)
+		case "keywords", "tags":
+			switch value := value.(type) {
+			case []interface{}:
+				m.Keywords = nil
+				for _, v := range value {
+					if v != nil {
+						m.Keywords = append(m.Keywords, metadataString(v))
+					}
+				}
+			default:
+				m.Keywords = []string{metadataString(value)}
+			}
+		case "creator":
+			m.Creator = metadataString(value)
+		case "date", "creationdate":
+			switch value := value.(type) {
+			case time.Time:
+				m.CreationDate = value
+			case string:
+				for _, layout := range metadataDateLayouts {
+					if t, err := time.Parse(layout, value); err == nil {
+						m.CreationDate = t
+						break
+					}
+				}
+			}
+		}
+	}
+	return m
+}
+
+func (m Metadata) apply(fpdf *gofpdf.Fpdf) {
+	if m.Title != "" {
+		fpdf.SetTitle(m.Title, true)
+	}
+	if m.Author != "" {
+		fpdf.SetAuthor(m.Author, true)
+	}
+	if m.Subject != "" {
+		fpdf.SetSubject(m.Subject, true)
+	}
+	if len(m.Keywords) != 0 {
+		fpdf.SetKeywords(strings.Join(m.Keywords, ", "), true)
+	}
+	if m.Creator != "" {
+		fpdf.SetCreator(m.Creator, true)
+	}
+	if !m.CreationDate.IsZero() {
+		fpdf.SetCreationDate(m.CreationDate)
+	}
+}
+
+// metadataString converts a value of the front matter into a string.
+// A list such as multiple authors is joined with commas.
+func metadataString(value interface{}) string {
+	if list, ok := value.([]interface{}); ok {
+		items := []string{}
+		for _, v := range list {
+			if v != nil {
+				items = append(items, metadataString(v))
+			}
+		}
+		return strings.Join(items, ", ")
+	}
+	return fmt.Sprint(value)
+}
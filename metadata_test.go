@@ -0,0 +1,70 @@
+package goldpdf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+func TestMetadataMerge(t *testing.T) {
+	source := []byte("text\n")
+	doc := goldmark.New().Parser().Parse(text.NewReader(source)).(*ast.Document)
+	doc.SetMeta(map[string]interface{}{
+		"Title":    "Report",
+		"authors":  []interface{}{"Alice", nil, "Bob"},
+		"subject":  nil,
+		"tags":     []interface{}{"a", "b"},
+		"date":     "2024-03-01",
+		"unknown":  "ignored",
+		"creator":  12,
+		"keywords": nil,
+	})
+
+	options := Metadata{Subject: "Default subject", Keywords: []string{"default"}}
+	got := options.merge(doc)
+	want := Metadata{
+		Title:        "Report",
+		Author:       "Alice, Bob",
+		Subject:      "Default subject",
+		Keywords:     []string{"a", "b"},
+		Creator:      "12",
+		CreationDate: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if got.Title != want.Title || got.Author != want.Author || got.Subject != want.Subject || strings.Join(got.Keywords, ",") != strings.Join(want.Keywords, ",") || got.Creator != want.Creator || !got.CreationDate.Equal(want.CreationDate) {
+		t.Errorf("merge() = %+v, want %+v", got, want)
+	}
+
+	// The merged metadata is written to the document information of the PDF
+	r := New(WithMetadata(options), WithPDFProvider(func() *gofpdf.Fpdf {
+		fpdf := gofpdf.New(gofpdf.OrientationPortrait, "pt", "A4", ".")
+		fpdf.SetCompression(false)
+		return fpdf
+	}))
+	buf := &bytes.Buffer{}
+	if err := r.Render(buf, source, doc); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"Report", "Alice, Bob", "Default subject"} {
+		if !bytes.Contains(buf.Bytes(), utf16BE(s)) {
+			t.Errorf("%q is not written to the PDF", s)
+		}
+	}
+	if !strings.Contains(buf.String(), "/CreationDate (D:20240301000000") {
+		t.Errorf("the creation date is not written to the PDF")
+	}
+}
+
+// utf16BE returns the ASCII string s encoded in UTF-16BE, as gofpdf writes the UTF-8 document information.
+func utf16BE(s string) []byte {
+	b := []byte{}
+	for _, c := range []byte(s) {
+		b = append(b, 0, c)
+	}
+	return b
+}
@@ -0,0 +1,65 @@
+package goldpdf
+
+import (
+	"strconv"
+	"strings"
+
+	xast "github.com/yuin/goldmark/extension/ast"
+)
+
+var (
+	_ PageDecorator = &PageNumberDecorator{}
+)
+
+// PageDecorator draws running headers and footers on every page.
+type PageDecorator interface {
+	// ReservedHeights returns the heights of the header and footer areas of the page.
+	// These areas are excluded from the area where the body is laid out.
+	ReservedHeights(page int) (header, footer float64)
+	// Decorate is called for every page after the body has been laid out.
+	// body is the area of the page where the body is laid out; the header is above it and the footer is below it.
+	Decorate(rc RenderContext, page, totalPages int, body Rect) error
+}
+
+// PageNumberDecorator is a PageDecorator that draws a page number such as "Page 1 of 5" in the footer.
+type PageNumberDecorator struct {
+	Format TextFormat
+	// Template is the text of the page number. "{page}" and "{pages}" are replaced
+	// with the page number and the total page count. The default is "{page} / {pages}".
+	Template string
+	Align    xast.Alignment
+	// FooterHeight is the height of the footer area. The default is twice the font size.
+	FooterHeight float64
+}
+
+func (d *PageNumberDecorator) ReservedHeights(page int) (float64, float64) {
+	if d.FooterHeight == 0 {
+		return 0, d.Format.FontSize * 2
+	}
+	return 0, d.FooterHeight
+}
+
+func (d *PageNumberDecorator) Decorate(rc RenderContext, page, totalPages int, body Rect) error {
+	template := d.Template
+	if template == "" {
+		template = "{page} / {pages}"
+	}
+
+	text := &TextElement{
+		Format: d.Format,
+		Text:   strings.NewReplacer("{page}", strconv.Itoa(page), "{pages}", strconv.Itoa(totalPages)).Replace(template),
+	}
+
+	x := body.Left
+	switch d.Align {
+	case xast.AlignLeft:
+	case xast.AlignRight:
+		x = body.Right - rc.GetTextWidth(text)
+	default:
+		x += (body.Width() - rc.GetTextWidth(text)) / 2
+	}
+
+	_, footer := d.ReservedHeights(page)
+	rc.DrawText(page, x, body.Bottom.Position+(footer-d.Format.FontSize)/2, text)
+	return nil
+}
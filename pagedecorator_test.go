@@ -0,0 +1,44 @@
+package goldpdf
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// recordingDecorator records the arguments of the calls to Decorate.
+type recordingDecorator struct {
+	calls []string
+}
+
+func (d *recordingDecorator) ReservedHeights(page int) (float64, float64) {
+	return 20, 20
+}
+
+func (d *recordingDecorator) Decorate(rc RenderContext, page, totalPages int, body Rect) error {
+	d.calls = append(d.calls, fmt.Sprintf("%d/%d", page, totalPages))
+	return nil
+}
+
+func TestPageDecoratorTotalPages(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"# Title\n\ntext\n- item\n\n```\ncode\n```\n", "1/1"},
+		{"one\n\n<div style=\"page-break-after: always\"></div>\n\ntwo\n", "1/2 2/2"},
+	}
+	for _, tt := range tests {
+		decorator := &recordingDecorator{}
+		renderMarkdown(t, tt.source, WithPageDecorator(decorator))
+		if got := strings.Join(decorator.calls, " "); got != tt.want {
+			t.Errorf("Decorate is called with %q, want %q", got, tt.want)
+		}
+	}
+
+	_, output := renderMarkdown(t, "# Title\n\ntext\n", WithPageDecorator(&PageNumberDecorator{Format: TextFormat{FontFamily: "Arial", FontSize: 10, Color: color.Black}}))
+	if !strings.Contains(output, "(1 / 1)") {
+		t.Errorf("the page number of a single page document is not \"1 / 1\"")
+	}
+}
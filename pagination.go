@@ -0,0 +1,271 @@
+package goldpdf
+
+import (
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	xast "github.com/yuin/goldmark/extension/ast"
+)
+
+// avoidPageBreak returns the top of the first node of the nodes placed at contentBox.Top.
+// It is moved to the top of the next page if the part of the nodes that should not be broken,
+// as specified by KeepWithNext and AvoidBreakInside, does not fit in the rest of the page but fits on a new page.
+// It also reports whether the whole first node is known to fit in the page where it is placed.
+// Nothing is measured at the top of a page, on an endless page, or inside a node known to fit in the page,
+// where the nodes are never moved, so that nested blocks are not measured again for each of their ancestors.
+func (r *Renderer) avoidPageBreak(nodes []ast.Node, mc MeasureContext, contentBox HalfBounds) (VerticalCoord, bool, error) {
+	top := contentBox.Top
+	pageTop, pageBottom := mc.GetPageVerticalBounds(top.Page)
+	if top.Position <= pageTop || math.IsInf(pageBottom, 1) || top.Page == r.fittingPage {
+		return top, false, nil
+	}
+
+	height, err := r.keptHeight(nodes, mc, contentBox)
+	if err != nil {
+		return VerticalCoord{}, false, err
+	}
+
+	top = breakBefore(mc, top, height)
+	_, pageBottom = mc.GetPageVerticalBounds(top.Page)
+	bs := r.blockStyle(nodes[0])
+	measured := bs.AvoidBreakInside || bs.KeepWithNext && len(nodes) > 1 // see keptHeight
+	return top, measured && top.Position+height <= pageBottom, nil
+}
+
+// breakBefore returns the top of the next page if the content of the height placed at top
+// does not fit in the rest of the page but fits on a new page, and top itself otherwise.
+func breakBefore(mc MeasureContext, top VerticalCoord, height float64) VerticalCoord {
+	pageTop, pageBottom := mc.GetPageVerticalBounds(top.Page)
+	if top.Position <= pageTop || top.Position+height <= pageBottom {
+		return top
+	}
+	if nextTop, nextBottom := mc.GetPageVerticalBounds(top.Page + 1); height <= nextBottom-nextTop {
+		return VerticalCoord{Page: top.Page + 1, Position: nextTop}
+	}
+	return top
+}
+
+// keptHeight returns the height from the top of the first node of the nodes placed at contentBox.Top
+// to the point where a page break is allowed.
+func (r *Renderer) keptHeight(nodes []ast.Node, mc MeasureContext, contentBox HalfBounds) (float64, error) {
+	n := nodes[0]
+	bs := r.blockStyle(n)
+	borderBox := contentBox.Shrink(horizontalSpacing(bs.Margin))
+
+	keepWithNext := bs.KeepWithNext && len(nodes) > 1
+	if !bs.AvoidBreakInside && !keepWithNext {
+		return r.firstLinesHeight(n, mc, borderBox, bs.Orphans)
+	}
+
+	rect, err := r.measureBlockNode(n, mc, borderBox)
+	if err != nil {
+		return 0, err
+	}
+	height := rect.Bottom.Position - rect.Top.Position
+	if !keepWithNext {
+		return height, nil
+	}
+
+	_, marginBottom := r.collapsedMargins(n)
+	marginTop, _ := r.collapsedMargins(nodes[1])
+	contentBox.Top.Position += height + collapseMargins(marginBottom, marginTop)
+	nextHeight, err := r.keptHeight(nodes[1:], mc, contentBox)
+	if err != nil {
+		return 0, err
+	}
+	return contentBox.Top.Position - rect.Top.Position + nextHeight, nil
+}
+
+// firstLinesHeight returns the height from the top of the node placed in the borderBox to the bottom of its first lines,
+// which are at least one line and at most count lines.
+func (r *Renderer) firstLinesHeight(n ast.Node, mc MeasureContext, borderBox HalfBounds, count int) (float64, error) {
+	bs := r.blockStyle(n)
+	contentBox := borderBox.Shrink(bs.Border, bs.Padding)
+	height := contentBox.Top.Position - borderBox.Top.Position
+
+	elements, err := r.getFlowElements(n)
+	if err != nil {
+		return 0, err
+	}
+	if len(elements) != 0 {
+		lines, _ := wrapLines(mc, contentBox.Width(), elements, bs)
+		for i, line := range lines {
+			if i != 0 && i >= count {
+				break
+			}
+			_, lineHeight := getLineSize(mc, line)
+			height += lineHeight
+		}
+		return height, nil
+	}
+
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		if c.Type() == ast.TypeBlock {
+			childBox := r.firstChildBorderBox(n, contentBox)
+			childHeight, err := r.firstLinesHeight(c, mc, childBox, r.blockStyle(c).Orphans)
+			if err != nil {
+				return 0, err
+			}
+			return height + childBox.Top.Position - contentBox.Top.Position + childHeight, nil
+		}
+	}
+	return height, nil
+}
+
+// measureBlockNode returns the border box of the block node placed in the borderBox on an endless page.
+func (r *Renderer) measureBlockNode(n ast.Node, mc MeasureContext, borderBox HalfBounds) (Rect, error) {
+	defer r.snapshotFootnotePlacements()()
+	return r.renderBlockNode(n, unpaginatedContext{mc}, borderBox)
+}
+
+// snapshotFootnotePlacements returns a function that restores the placements of the footnotes to the current ones,
+// to undo the placements made while laying out the content tentatively.
+func (r *Renderer) snapshotFootnotePlacements() func() {
+	if r.footnotePlacements == nil {
+		return func() {}
+	}
+
+	snapshot := map[*xast.Footnote]footnotePlacement{}
+	for fn, p := range r.footnotePlacements {
+		snapshot[fn] = p
+	}
+	return func() {
+		r.footnotePlacements = map[*xast.Footnote]footnotePlacement{}
+		for fn, p := range snapshot {
+			r.footnotePlacements[fn] = p
+		}
+	}
+}
+
+// paginateLines returns the top of each line placed from top, moving lines to the next page where they do not fit
+// and placing the footnotes referenced in them.
+// Lines are moved to the next page earlier if needed to leave the Orphans and Widows of bs.
+func (r *Renderer) paginateLines(mc MeasureContext, lines [][]InlineElement, top VerticalCoord, bs BlockStyle) ([]VerticalCoord, error) {
+	restore := r.snapshotFootnotePlacements()
+	breaks := map[int]bool{}
+	for {
+		tops, err := r.layoutLines(mc, lines, top, breaks)
+		if err != nil {
+			return nil, err
+		}
+
+		i, ok := widowOrphanBreak(tops, bs.Orphans, bs.Widows)
+		if !ok || breaks[i] {
+			return tops, nil
+		}
+		breaks[i] = true
+		restore()
+	}
+}
+
+// layoutLines returns the top of each line placed from top.
+// A line that does not fit in the rest of the page, or whose index is in breaks, starts a new page.
+func (r *Renderer) layoutLines(mc MeasureContext, lines [][]InlineElement, top VerticalCoord, breaks map[int]bool) ([]VerticalCoord, error) {
+	tops := make([]VerticalCoord, len(lines))
+	for i, line := range lines {
+		_, lineHeight := getLineSize(mc, line)
+
+		// The line and the footnotes referenced in it must fit on the same page
+		footnotes, err := r.lineFootnotes(mc, line, top.Page)
+		if err != nil {
+			return nil, err
+		}
+		pageTop, pageBottom := mc.GetPageVerticalBounds(top.Page)
+		pageBottom += r.footnoteAreaHeight(top.Page, nil) - r.footnoteAreaHeight(top.Page, footnotes)
+		if top.Position+lineHeight > pageBottom || breaks[i] && top.Position > pageTop {
+			top.Page++
+			top.Position, _ = mc.GetPageVerticalBounds(top.Page)
+			if footnotes, err = r.lineFootnotes(mc, line, top.Page); err != nil {
+				return nil, err
+			}
+		}
+		r.placeFootnotes(footnotes, top.Page)
+
+		tops[i] = top
+		top.Position += lineHeight
+	}
+	return tops, nil
+}
+
+// widowOrphanBreak returns the index of the first line that should start a new page
+// so that at least orphans lines are left before each page break and at least widows lines follow it.
+// It returns false if the lines at the tops already satisfy them or cannot be moved to do so.
+func widowOrphanBreak(tops []VerticalCoord, orphans, widows int) (int, bool) {
+	// The index of the first line on each page
+	starts := []int{0}
+	for i := 1; i < len(tops); i++ {
+		if tops[i].Page != tops[i-1].Page {
+			starts = append(starts, i)
+		}
+	}
+	starts = append(starts, len(tops))
+
+	if orphans < 1 {
+		orphans = 1
+	}
+	for j := 1; j < len(starts)-1; j++ {
+		prev, start, next := starts[j-1], starts[j], starts[j+1]
+		if start-prev < orphans {
+			if j == 1 {
+				return 0, true // move the whole beginning of the block to the next page
+			}
+			continue
+		}
+		if next-start < widows {
+			if i := start - (widows - (next - start)); i-prev >= orphans {
+				return i, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// nextPageTop returns the top of the next page, or vc itself if it is already at the top of a page,
+// so that consecutive page breaks do not produce blank pages.
+func nextPageTop(mc MeasureContext, vc VerticalCoord) VerticalCoord {
+	if pageTop, _ := mc.GetPageVerticalBounds(vc.Page); vc.Position <= pageTop {
+		return VerticalCoord{Page: vc.Page, Position: pageTop}
+	}
+	top, _ := mc.GetPageVerticalBounds(vc.Page + 1)
+	return VerticalCoord{Page: vc.Page + 1, Position: top}
+}
+
+// hasFollowingContent reports whether any block follows the node in the document flow.
+// A page break after the last block is ignored so that the document does not end with a blank page.
+func (r *Renderer) hasFollowingContent(n ast.Node) bool {
+	for p := n; p != nil; p = p.Parent() {
+		for s := p.NextSibling(); s != nil; s = s.NextSibling() {
+			if _, ok := s.(*xast.FootnoteList); ok && r.footnotePlacements != nil {
+				continue // drawn at the bottom of the pages instead
+			}
+			if s.Type() == ast.TypeBlock {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pageBreakMarkerPattern matches the CSS properties that force a page break in the style attribute of an HTML block,
+// such as <div style="page-break-after: always"></div>.
+var pageBreakMarkerPattern = regexp.MustCompile(`(?i)(?:page-)?break-(before|after)\s*:\s*(?:always|page)`)
+
+// pageBreakMarker reports whether the HTML block forces a page break before or after it.
+func (r *Renderer) pageBreakMarker(n *ast.HTMLBlock) (before, after bool) {
+	html := ""
+	for i := 0; i < n.Lines().Len(); i++ {
+		line := n.Lines().At(i)
+		html += string(line.Value(r.source))
+	}
+
+	for _, m := range pageBreakMarkerPattern.FindAllStringSubmatch(html, -1) {
+		if strings.EqualFold(m[1], "before") {
+			before = true
+		} else {
+			after = true
+		}
+	}
+	return before, after
+}
@@ -0,0 +1,146 @@
+package goldpdf
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+func TestWidowOrphanBreak(t *testing.T) {
+	// pages returns the tops of lines placed on the pages in order
+	pages := func(counts ...int) []VerticalCoord {
+		tops := []VerticalCoord{}
+		for page, count := range counts {
+			for i := 0; i < count; i++ {
+				tops = append(tops, VerticalCoord{Page: page + 1, Position: float64(i * 12)})
+			}
+		}
+		return tops
+	}
+
+	tests := []struct {
+		name      string
+		tops      []VerticalCoord
+		wantIndex int
+		wantOK    bool
+	}{
+		{"single page", pages(5), 0, false},
+		{"satisfied", pages(3, 2), 0, false},
+		{"orphan", pages(1, 4), 0, true},
+		{"widow", pages(4, 1), 3, true},
+		{"widow and orphan conflict", pages(2, 1), 0, false},
+		{"widow before another page break", pages(5, 1, 3), 4, true},
+	}
+	for _, tt := range tests {
+		index, ok := widowOrphanBreak(tt.tops, 2, 2)
+		if index != tt.wantIndex || ok != tt.wantOK {
+			t.Errorf("%s: widowOrphanBreak() = %v, %v, want %v, %v", tt.name, index, ok, tt.wantIndex, tt.wantOK)
+		}
+	}
+}
+
+func TestPageBreakMarker(t *testing.T) {
+	tests := []struct {
+		html          string
+		before, after bool
+	}{
+		{`<div style="page-break-after: always"></div>`, false, true},
+		{`<div style="break-before: page"></div>`, true, false},
+		{`<div style="color: red"></div>`, false, false},
+	}
+	for _, tt := range tests {
+		source := []byte(tt.html + "\n")
+		r := newRenderer()
+		r.source = source
+
+		n, ok := goldmark.New().Parser().Parse(text.NewReader(source)).FirstChild().(*ast.HTMLBlock)
+		if !ok {
+			t.Fatalf("%s is not parsed as an HTML block", tt.html)
+		}
+		if before, after := r.pageBreakMarker(n); before != tt.before || after != tt.after {
+			t.Errorf("pageBreakMarker(%s) = %v, %v, want %v, %v", tt.html, before, after, tt.before, tt.after)
+		}
+	}
+}
+
+func TestPageBreakAfterLastBlock(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		options []Option
+		want    int
+	}{
+		{"marker", "text\n\n<div style=\"page-break-after: always\"></div>\n", nil, 1},
+		{"marker in a blockquote", "> text\n> <div style=\"page-break-after: always\"></div>\n", nil, 1},
+		{"thematic break", "text\n\n---\n", []Option{WithStyler(&DefaultStyler{FontFamily: "Arial", FontSize: 12, Color: color.Black, ThematicBreakAsPageBreak: true})}, 1},
+		{"followed by content", "text\n\n<div style=\"page-break-after: always\"></div>\n\nmore\n", nil, 2},
+	}
+	for _, tt := range tests {
+		fpdf, _ := renderMarkdown(t, tt.source, tt.options...)
+		if got := fpdf.PageCount(); got != tt.want {
+			t.Errorf("%s: PageCount() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBreakBefore(t *testing.T) {
+	fpdf := gofpdf.New("P", "pt", "A4", "")
+	fpdf.AddPage()
+	mc := &renderContextImpl{fpdf: fpdf}
+	pageTop, pageBottom := mc.GetPageVerticalBounds(1)
+
+	tests := []struct {
+		name   string
+		top    VerticalCoord
+		height float64
+		want   VerticalCoord
+	}{
+		{"fits", VerticalCoord{Page: 1, Position: pageTop + 100}, 50, VerticalCoord{Page: 1, Position: pageTop + 100}},
+		{"moved", VerticalCoord{Page: 1, Position: pageBottom - 20}, 50, VerticalCoord{Page: 2, Position: pageTop}},
+		{"taller than a page", VerticalCoord{Page: 1, Position: pageBottom - 20}, pageBottom, VerticalCoord{Page: 1, Position: pageBottom - 20}},
+		{"already at the page top", VerticalCoord{Page: 2, Position: pageTop}, pageBottom, VerticalCoord{Page: 2, Position: pageTop}},
+	}
+	for _, tt := range tests {
+		if got := breakBefore(mc, tt.top, tt.height); got != tt.want {
+			t.Errorf("%s: breakBefore() = %v, want %v", tt.name, got, tt.want)
+		}
+		// Pages are added only when something is drawn on them
+		if got := fpdf.PageCount(); got != 1 {
+			t.Errorf("%s: breakBefore() added a page, PageCount() = %d", tt.name, got)
+		}
+	}
+}
+
+func TestAvoidBreakInsideNestedList(t *testing.T) {
+	// A nested list item is moved to the next page as a whole wherever the page breaks
+	for filler := 30; filler < 60; filler++ {
+		source := strings.Repeat("line\n\n", filler) + "- alpha\n  - beta\n    - gamma\n"
+		_, output := renderMarkdown(t, source)
+		pages := map[string]int{}
+		for i, page := range pageContents(output) {
+			for _, word := range []string{"alpha", "beta", "gamma"} {
+				if strings.Contains(page, "("+word+")") {
+					pages[word] = i + 1
+				}
+			}
+		}
+		if pages["alpha"] == 0 || pages["alpha"] != pages["beta"] || pages["beta"] != pages["gamma"] {
+			t.Errorf("%d lines before: the nested list item is broken across pages %v", filler, pages)
+		}
+	}
+
+	// The cost does not grow exponentially with the nesting depth
+	source := ""
+	for i := 0; i < 20; i++ {
+		source += strings.Repeat("  ", i) + "- item\n"
+	}
+	_, output := renderMarkdown(t, source)
+	if got := strings.Count(output, "(item)"); got != 20 {
+		t.Errorf("%d items are drawn, want 20", got)
+	}
+}
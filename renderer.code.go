@@ -0,0 +1,153 @@
+package goldpdf
+
+import (
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// renderCodeBlock draws a code block line by line so that each source line can have a line number
+// and the lines wider than the content box are handled according to BlockStyle.Overflow.
+func (r *Renderer) renderCodeBlock(n ast.Node, mc MeasureContext, borderBox HalfBounds) (Rect, error) {
+	bs, tf := r.blockStyleTextFormat(n)
+
+	err := mc.GetRenderContext(func(rc RenderContext) error {
+		defer r.snapshotFootnotePlacements()()
+		rect, err := r.renderCodeBlock(n, mc, borderBox)
+		if err != nil {
+			return err
+		}
+		rc.DrawBox(rect, bs.BackgroundColor, bs.Border)
+		return nil
+	})
+	if err != nil {
+		return Rect{}, err
+	}
+
+	elements, err := r.getFlowElements(n)
+	if err != nil {
+		return Rect{}, err
+	}
+	lines := splitSourceLines(elements, tf)
+
+	contentBox := borderBox.Shrink(bs.Border, bs.Padding)
+	markerColor := bs.LineNumberColor
+	if markerColor == nil {
+		markerColor = color.Gray{Y: 0x99}
+	}
+	numberFormat := tf
+	numberFormat.Color = markerColor
+	numberFormat.Bold, numberFormat.Italic, numberFormat.Underline, numberFormat.Strike = false, false, false, false
+
+	gutterWidth := 0.0
+	if bs.LineNumbers {
+		digits := &TextElement{Format: numberFormat, Text: strings.Repeat("0", len(strconv.Itoa(len(lines))))}
+		gutterWidth = mc.GetTextWidth(digits) + numberFormat.FontSize
+	}
+
+	if bs.Overflow == OverflowShrink {
+		// The gutter shrinks along with the code since both are proportional to the font size
+		if naturalWidth := GetNaturalWidth(mc, elements); naturalWidth+gutterWidth > contentBox.Width() && contentBox.Width() > 0 {
+			scale := contentBox.Width() / (naturalWidth + gutterWidth)
+			for _, line := range lines {
+				for i, e := range line {
+					line[i] = scaleElement(e, scale)
+				}
+			}
+			numberFormat.FontSize *= scale
+			gutterWidth *= scale
+		}
+	}
+
+	codeBox := contentBox
+	codeBox.Left += gutterWidth
+	markerWidth := tf.FontSize * 0.8
+
+	for i, line := range lines {
+		var visualLines [][]InlineElement
+		switch {
+		case GetNaturalWidth(mc, line) <= codeBox.Width(), bs.Overflow == OverflowWrap && !bs.WhiteSpace.wraps():
+			visualLines = [][]InlineElement{line}
+		case bs.Overflow == OverflowClip:
+			visualLines = [][]InlineElement{clipElements(mc, codeBox.Width(), line)}
+		default:
+			// Reserve the space for the continuation marker
+			visualLines = getLineBreaker(bs)(mc, codeBox.Width()-markerWidth, line, bs)
+		}
+
+		for j, visualLine := range visualLines {
+			rect, err := r.renderInlineElements(visualLine, mc, codeBox, bs)
+			if err != nil {
+				return Rect{}, err
+			}
+
+			err = mc.GetRenderContext(func(rc RenderContext) error {
+				baseline := rect.Top.Position + getLineBaseline(mc, visualLine)
+				if j == 0 && bs.LineNumbers {
+					number := &TextElement{Format: numberFormat, Text: strconv.Itoa(i + 1)}
+					x := codeBox.Left - numberFormat.FontSize - rc.GetTextWidth(number)
+					rc.DrawText(rect.Top.Page, x, baseline-numberFormat.FontSize, number)
+				}
+				if rc, ok := rc.(*renderContextImpl); ok && j != len(visualLines)-1 {
+					drawContinuationMarker(rc, rect.Top.Page, codeBox.Right-markerWidth, baseline-markerWidth, markerWidth, markerColor)
+				}
+				return nil
+			})
+			if err != nil {
+				return Rect{}, err
+			}
+
+			codeBox.Top = rect.Bottom
+		}
+	}
+
+	boxBottom := codeBox.Top
+	boxBottom.Position += bottom(bs.Padding) + bottom(bs.Border)
+	return borderBox.ToRect(boxBottom), nil
+}
+
+// splitSourceLines splits the elements of a code block at line breaks.
+// An empty line gets an empty text so that it keeps its height.
+func splitSourceLines(elements []InlineElement, tf TextFormat) [][]InlineElement {
+	lines := splitLineBreaks(elements)
+	if len(lines) > 1 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1] // the code block ends with a line break
+	}
+	for i, line := range lines {
+		if len(line) == 0 {
+			lines[i] = []InlineElement{&TextElement{Format: tf}}
+		}
+	}
+	return lines
+}
+
+// scaleElement returns a copy of the element with its font size scaled.
+func scaleElement(e InlineElement, scale float64) InlineElement {
+	switch e := e.(type) {
+	case *TextElement:
+		e2 := *e
+		e2.Format.FontSize *= scale
+		e2.Format.BaselineShift *= scale
+		return &e2
+	case *ImageElement:
+		e2 := *e
+		e2.Width *= scale
+		e2.Height *= scale
+		return &e2
+	default:
+		return e
+	}
+}
+
+// drawContinuationMarker draws a hooked arrow indicating that the line continues on the next line.
+func drawContinuationMarker(rc *renderContextImpl, page int, x, y, size float64, c color.Color) {
+	edge := BorderEdge{Width: math.Max(size/10, 0.5), Color: c}
+	right, bottom := x+size*0.8, y+size*0.65
+	rc.DrawLine(page, right, y+size*0.2, right, bottom, edge)
+	rc.DrawLine(page, right, bottom, x+size*0.2, bottom, edge)
+	rc.DrawLine(page, x+size*0.2, bottom, x+size*0.45, bottom-size*0.2, edge)
+	rc.DrawLine(page, x+size*0.2, bottom, x+size*0.45, bottom+size*0.2, edge)
+}
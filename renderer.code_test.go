@@ -0,0 +1,81 @@
+package goldpdf
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+func TestRenderCodeBlock(t *testing.T) {
+	longLine := strings.Repeat("b", 100)
+	source := "```\na\n" + longLine + "\n```\n"
+	styler := func(overflow Overflow) Option {
+		return WithStyler(&DefaultStyler{FontFamily: "Arial", FontSize: 12, Color: color.Black, CodeLineNumbers: true, CodeOverflow: overflow})
+	}
+
+	// The long line is wrapped with the line numbers drawn once for each source line
+	_, output := renderMarkdown(t, source, styler(OverflowWrap))
+	for _, number := range []string{"(1) Tj", "(2) Tj"} {
+		if got := strings.Count(output, number); got != 1 {
+			t.Errorf("wrap: the line number %s is drawn %d times, want 1", number, got)
+		}
+	}
+	if strings.Contains(output, "("+longLine+")") {
+		t.Errorf("wrap: the long line is not wrapped")
+	}
+
+	// The long line and the gutter are shrunk together to fill the content box
+	fpdf, output := renderMarkdown(t, source, styler(OverflowShrink))
+	m := regexp.MustCompile(`([\d.]+) Tf ET\s+(?:/GS1 gs\s+)?q [^\n]*BT ([\d.]+) [\d.]+ Td \(` + longLine + `\) Tj`).FindStringSubmatch(output)
+	if m == nil {
+		t.Fatalf("shrink: the long line is not drawn in one piece")
+	}
+	fontSize, _ := strconv.ParseFloat(m[1], 64)
+	x, _ := strconv.ParseFloat(m[2], 64)
+	fpdf.SetFont("Arial", "", fontSize)
+	pageWidth, _ := fpdf.GetPageSize()
+	_, _, rm, _ := fpdf.GetMargins()
+	right := pageWidth - rm - 10.5 // the border and padding of the code block
+	if got := x + fpdf.GetStringWidth(longLine); math.Abs(got-right) > 0.5 {
+		t.Errorf("shrink: the long line ends at %v, want %v", got, right)
+	}
+
+	// The line numbers are drawn in the LineNumberColor
+	red := color.RGBA{R: 0xFF, A: 0xFF}
+	_, output = renderMarkdown(t, source, WithStyler(&lineNumberColorStyler{DefaultStyler{FontFamily: "Arial", FontSize: 12, Color: color.Black, CodeLineNumbers: true}, red}))
+	if !strings.Contains(output, "q 1.000 0.000 0.000 rg BT") {
+		t.Errorf("the line numbers are not drawn in the LineNumberColor")
+	}
+}
+
+// lineNumberColorStyler sets the LineNumberColor of the blocks.
+type lineNumberColorStyler struct {
+	DefaultStyler
+	color color.Color
+}
+
+func (s *lineNumberColorStyler) Style(n ast.Node, tf TextFormat) (BlockStyle, TextFormat) {
+	bs, tf := s.DefaultStyler.Style(n, tf)
+	bs.LineNumberColor = s.color
+	return bs, tf
+}
+
+func TestSplitSourceLines(t *testing.T) {
+	tf := TextFormat{FontFamily: "Courier", FontSize: 10, Color: color.Black}
+	elements := []InlineElement{
+		&TextElement{Format: tf, Text: "a"},
+		&LineBreakElement{Format: tf},
+		&LineBreakElement{Format: tf},
+		&TextElement{Format: tf, Text: "b"},
+		&LineBreakElement{Format: tf},
+	}
+	if got := fmt.Sprint(splitSourceLines(elements, tf)); got != "[[a] [] [b]]" {
+		t.Errorf("splitSourceLines() = %v", got)
+	}
+}
@@ -1,6 +1,7 @@
 package goldpdf
 
 import (
+	"strconv"
 	"strings"
 
 	"github.com/yuin/goldmark/ast"
@@ -9,26 +10,45 @@ import (
 
 // getFlowElements retrieves the FlowElement belonging to the specified node.
 // Belonging means "a descendant inline node of the node and not a descendant of a child block node of the node."
+// White space in the text is processed according to the WhiteSpace of the node's BlockStyle,
+// and the texts are split into runs by the font family having their glyphs.
 func (r *Renderer) getFlowElements(n ast.Node) ([]InlineElement, error) {
+	elements, err := r.collectFlowElements(n)
+	if err != nil {
+		return nil, err
+	}
+	return r.applyFontFallback(applyWhiteSpace(elements, r.blockStyle(n))), nil
+}
+
+// collectFlowElements collects the inline elements of the node and its inline descendants as they are in the source.
+func (r *Renderer) collectFlowElements(n ast.Node) ([]InlineElement, error) {
 	elements := []InlineElement{}
 
 	switch n := n.(type) {
-	case *ast.CodeBlock, *ast.FencedCodeBlock:
-		tf := r.textFormat(n)
-		lines := n.Lines()
-		for i := 0; i < lines.Len(); i++ {
-			line := lines.At(i)
-			str := string(line.Value(r.source))
-
-			text := &TextElement{Text: strings.TrimSuffix(str, "\n"), Format: tf}
-			elements = append(elements, text)
-			if strings.HasSuffix(str, "\n") {
-				elements = append(elements, &LineBreakElement{Format: tf})
+	case *ast.FencedCodeBlock:
+		if r.highlighter != nil {
+			code := ""
+			for i := 0; i < n.Lines().Len(); i++ {
+				line := n.Lines().At(i)
+				code += string(line.Value(r.source))
 			}
+			e, err := r.highlighter.Highlight(string(n.Language(r.source)), code, r.textFormat(n))
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, e...)
+		} else {
+			elements = append(elements, r.getCodeLineElements(n)...)
 		}
+	case *ast.CodeBlock:
+		elements = append(elements, r.getCodeLineElements(n)...)
 	case *ast.AutoLink:
 		tf := r.textFormat(n)
-		text := &TextElement{Format: tf, Text: string(n.URL(r.source))}
+		dest := string(n.URL(r.source))
+		text := &TextElement{Format: tf, Text: dest, Link: dest}
+		if n.AutoLinkType == ast.AutoLinkEmail && !strings.HasPrefix(strings.ToLower(dest), "mailto:") {
+			text.Link = "mailto:" + dest
+		}
 		elements = append(elements, text)
 	case *ast.Text:
 		tf := r.textFormat(n)
@@ -36,7 +56,27 @@ func (r *Renderer) getFlowElements(n ast.Node) ([]InlineElement, error) {
 		elements = append(elements, text)
 		if n.HardLineBreak() {
 			elements = append(elements, &LineBreakElement{Format: tf})
+		} else if n.SoftLineBreak() {
+			// Kept as a newline character and resolved by applyWhiteSpace
+			text.Text += "\n"
+		}
+	case *xast.FootnoteLink:
+		tf := r.textFormat(n)
+		text := &TextElement{
+			Format:   tf,
+			Text:     strconv.Itoa(n.Index),
+			Link:     "#" + footnoteDestination(n.Index),
+			footnote: r.footnotes[n.Index],
 		}
+		elements = append(elements, text)
+	case *xast.FootnoteBacklink:
+		tf := r.textFormat(n)
+		text := &TextElement{
+			Format: tf,
+			Text:   "^",
+			Link:   "#" + footnoteReferenceDestination(n.Index),
+		}
+		elements = append(elements, text)
 	case *ast.Image:
 		img, err := r.imageLoader.LoadImage(string(n.Destination))
 		if err != nil {
@@ -61,7 +101,7 @@ func (r *Renderer) getFlowElements(n ast.Node) ([]InlineElement, error) {
 
 	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
 		if c.Type() == ast.TypeInline {
-			e, err := r.getFlowElements(c)
+			e, err := r.collectFlowElements(c)
 			if err != nil {
 				return nil, err
 			}
@@ -69,21 +109,81 @@ func (r *Renderer) getFlowElements(n ast.Node) ([]InlineElement, error) {
 		}
 	}
 
+	if n, ok := n.(*ast.Link); ok {
+		for i, e := range elements {
+			elements[i] = withLink(e, string(n.Destination))
+		}
+	}
+
 	return elements, nil
 }
 
+// firstLineBaseline returns the distance from the top of the content box of the block to the baseline of its first line,
+// to align markers such as list bullets with the text.
+func (r *Renderer) firstLineBaseline(n ast.Node, mc MeasureContext, contentBox HalfBounds) (float64, error) {
+	elements, err := r.getFlowElements(n)
+	if err != nil {
+		return 0, err
+	}
+	if len(elements) == 0 {
+		ascent, _ := getFontMetrics(mc, r.textFormat(n))
+		return ascent, nil
+	}
+
+	bs := r.blockStyle(n)
+	lines := getLineBreaker(bs)(mc, contentBox.Width(), elements, bs)
+	return getLineBaseline(mc, lines[0]), nil
+}
+
+// getCodeLineElements returns the lines of the code block as unformatted elements.
+func (r *Renderer) getCodeLineElements(n ast.Node) []InlineElement {
+	elements := []InlineElement{}
+	tf := r.textFormat(n)
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		str := string(line.Value(r.source))
+
+		text := &TextElement{Text: strings.TrimSuffix(str, "\n"), Format: tf}
+		elements = append(elements, text)
+		if strings.HasSuffix(str, "\n") {
+			elements = append(elements, &LineBreakElement{Format: tf})
+		}
+	}
+	return elements
+}
+
+// withLink returns a copy of the inline element that links to dest.
+// Elements are copied because images may be shared through the ImageLoader's cache.
+func withLink(e InlineElement, dest string) InlineElement {
+	switch e := e.(type) {
+	case *TextElement:
+		e2 := *e
+		e2.Link = dest
+		return &e2
+	case *ImageElement:
+		e2 := *e
+		e2.Link = dest
+		return &e2
+	default:
+		return e
+	}
+}
+
 // renderInlineElements draws inline elements inside the contentBox and returns a content box with the actual drawn height.
-func (r *Renderer) renderInlineElements(elements []InlineElement, mc MeasureContext, contentBox HalfBounds, align xast.Alignment) (Rect, error) {
+// Lines are wrapped and aligned according to bs.
+func (r *Renderer) renderInlineElements(elements []InlineElement, mc MeasureContext, contentBox HalfBounds, bs BlockStyle) (Rect, error) {
 	result := contentBox.ToRect(contentBox.Top)
 
-	for i, line := range wrapElements(mc, contentBox.Width(), elements) {
-		lineWidth, lineHeight := getLineSize(mc, line)
+	lines, endsParagraph := wrapLines(mc, contentBox.Width(), elements, bs)
+	tops, err := r.paginateLines(mc, lines, contentBox.Top, bs)
+	if err != nil {
+		return Rect{}, err
+	}
 
-		pageTop, pageBottom := mc.GetPageVerticalBounds(contentBox.Top.Page)
-		if contentBox.Top.Position+lineHeight > pageBottom {
-			contentBox.Top.Page++
-			contentBox.Top.Position = pageTop
-		}
+	for i, line := range lines {
+		lineWidth, lineHeight := getLineSize(mc, line)
+		contentBox.Top = tops[i]
 
 		if i == 0 {
 			result.Top = contentBox.Top
@@ -92,20 +192,26 @@ func (r *Renderer) renderInlineElements(elements []InlineElement, mc MeasureCont
 		result.Bottom = contentBox.Top
 		result.Bottom.Position += lineHeight
 
-		err := mc.GetRenderContext(func(rc RenderContext) error {
+		err = mc.GetRenderContext(func(rc RenderContext) error {
 			x := contentBox.Left
 			y := contentBox.Top.Position
 
-			switch align {
-			case xast.AlignRight:
-				x += contentBox.Width() - lineWidth
-			case xast.AlignCenter:
-				x += (contentBox.Width() - lineWidth) / 2
+			if bs.Justify && !endsParagraph[i] {
+				line = justifyLine(mc, line, contentBox.Width(), bs.TextJustify)
+			} else {
+				switch bs.TextAlign {
+				case xast.AlignRight:
+					x += contentBox.Width() - lineWidth
+				case xast.AlignCenter:
+					x += (contentBox.Width() - lineWidth) / 2
+				}
 			}
 
+			baseline := y + getLineBaseline(mc, line)
 			for _, e := range line {
-				w, h := e.size(mc)
-				e.drawTo(rc, contentBox.Top.Page, x, y+lineHeight-h)
+				w, _ := e.size(mc)
+				above, _ := e.metrics(mc)
+				e.drawTo(rc, contentBox.Top.Page, x, baseline-above)
 				x += w
 			}
 			return nil
@@ -113,9 +219,22 @@ func (r *Renderer) renderInlineElements(elements []InlineElement, mc MeasureCont
 		if err != nil {
 			return Rect{}, err
 		}
-
-		contentBox.Top.Position += lineHeight
 	}
 
 	return result, nil
 }
+
+// wrapLines breaks the elements into lines that fit in limitWidth.
+// Each paragraph separated by line breaks is wrapped separately to know which lines end it.
+func wrapLines(mc MeasureContext, limitWidth float64, elements []InlineElement, bs BlockStyle) ([][]InlineElement, []bool) {
+	lines := [][]InlineElement{}
+	endsParagraph := []bool{}
+	for _, paragraph := range splitLineBreaks(elements) {
+		wrapped := getLineBreaker(bs)(mc, limitWidth, paragraph, bs)
+		for j, line := range wrapped {
+			lines = append(lines, line)
+			endsParagraph = append(endsParagraph, j == len(wrapped)-1)
+		}
+	}
+	return lines, endsParagraph
+}
@@ -2,6 +2,7 @@ package goldpdf
 
 import (
 	"fmt"
+	"math"
 
 	"github.com/yuin/goldmark/ast"
 	xast "github.com/yuin/goldmark/extension/ast"
@@ -14,31 +15,87 @@ func (r *Renderer) renderBlockNode(n ast.Node, mc MeasureContext, borderBox Half
 		return Rect{}, fmt.Errorf("renderBlockNode has been called with an inline node: %v > %v", n.Parent().Kind(), n.Kind())
 	}
 
+	var rect Rect
+	var err error
 	switch n := n.(type) {
 	case *ast.ListItem:
-		return r.renderListItem(n, mc, borderBox)
+		rect, err = r.renderListItem(n, mc, borderBox)
 	case *xast.Table:
-		return r.renderTable(n, mc, borderBox)
+		rect, err = r.renderTable(n, mc, borderBox)
+	case *TableOfContentsEntry:
+		rect, err = r.renderTableOfContentsEntry(n, mc, borderBox)
+	case *ast.CodeBlock, *ast.FencedCodeBlock:
+		rect, err = r.renderCodeBlock(n, mc, borderBox)
+	case *xast.DefinitionList:
+		rect, err = r.renderDefinitionList(n, mc, borderBox)
+	case *xast.Footnote:
+		rect, err = r.renderFootnote(n, mc, borderBox)
+	case *xast.FootnoteList:
+		if r.footnotePlacements != nil {
+			// The footnotes are drawn at the bottom of the pages instead
+			return borderBox.ToRect(borderBox.Top), nil
+		}
+		rect, err = r.renderGenericBlockNode(n, mc, borderBox)
 	default:
-		return r.renderGenericBlockNode(n, mc, borderBox)
+		rect, err = r.renderGenericBlockNode(n, mc, borderBox)
+	}
+	if err != nil {
+		return Rect{}, err
+	}
+
+	// Register the node with an ID (e.g. a heading with an auto heading ID) as a link destination
+	if id := nodeID(n); id != "" {
+		err := mc.GetRenderContext(func(rc RenderContext) error {
+			if rc, ok := rc.(*renderContextImpl); ok {
+				rc.AddDestination(id, rect.Top)
+			}
+			return nil
+		})
+		if err != nil {
+			return Rect{}, err
+		}
+	}
+
+	if n, ok := n.(*ast.Heading); ok {
+		err := mc.GetRenderContext(func(rc RenderContext) error {
+			r.headingPages[n] = rect.Top.Page
+			if rc, ok := rc.(*renderContextImpl); ok && r.outline {
+				title := &TextElement{Format: r.textFormat(n), Text: string(n.Text(r.source))}
+				rc.AddBookmark(title, n.Level, rect.Top)
+			}
+			return nil
+		})
+		if err != nil {
+			return Rect{}, err
+		}
+	}
+
+	return rect, nil
+}
+
+// nodeID returns the value of the id attribute of the node, or an empty string.
+func nodeID(n ast.Node) string {
+	id, _ := n.AttributeString("id")
+	switch id := id.(type) {
+	case []byte:
+		return string(id)
+	case string:
+		return id
 	}
+	return ""
 }
 
 // renderGenericBlockNode provides basic rendering for all block nodes
 // except specific block nodes.
-func (r *Renderer) renderGenericBlockNode(n ast.Node, mc MeasureContext, borderBox HalfBounds, borderBoxBottom ...VerticalCoord) (Rect, error) {
+func (r *Renderer) renderGenericBlockNode(n ast.Node, mc MeasureContext, borderBox HalfBounds) (Rect, error) {
 	bs := r.blockStyle(n)
 
 	err := mc.GetRenderContext(func(rc RenderContext) error {
-		var b Rect
-		if len(borderBoxBottom) == 0 {
-			var err error
-			b, err = r.renderGenericBlockNode(n, mc, borderBox)
-			if err != nil {
-				return err
-			}
-		} else {
-			b = borderBox.ToRect(borderBoxBottom[0])
+		// The footnotes are placed by the rendering of the contents below, not by this measurement
+		defer r.snapshotFootnotePlacements()()
+		b, err := r.renderGenericBlockNode(n, mc, borderBox)
+		if err != nil {
+			return err
 		}
 
 		rc.DrawBox(b, bs.BackgroundColor, bs.Border)
@@ -48,6 +105,13 @@ func (r *Renderer) renderGenericBlockNode(n ast.Node, mc MeasureContext, borderB
 		return Rect{}, err
 	}
 
+	return r.renderBlockContents(n, mc, borderBox)
+}
+
+// renderBlockContents draws the inline elements or the child block nodes of the node inside the borderBox
+// without its background and border, and returns a border box with the actual drawn height.
+func (r *Renderer) renderBlockContents(n ast.Node, mc MeasureContext, borderBox HalfBounds) (Rect, error) {
+	bs := r.blockStyle(n)
 	contentBox := borderBox.Shrink(bs.Border, bs.Padding)
 
 	elements, err := r.getFlowElements(n)
@@ -55,7 +119,7 @@ func (r *Renderer) renderGenericBlockNode(n ast.Node, mc MeasureContext, borderB
 		return Rect{}, err
 	}
 	if len(elements) != 0 {
-		r, err := r.renderInlineElements(elements, mc, contentBox, bs.TextAlign)
+		r, err := r.renderInlineElements(elements, mc, contentBox, bs)
 		if err != nil {
 			return Rect{}, err
 		}
@@ -63,22 +127,163 @@ func (r *Renderer) renderGenericBlockNode(n ast.Node, mc MeasureContext, borderB
 		return r.Expand(bs.Border, bs.Padding), nil
 	} else {
 		// Render descendant block nodes
+		children := []ast.Node{}
 		for c := n.FirstChild(); c != nil; c = c.NextSibling() {
 			if c.Type() == ast.TypeBlock {
-				bs2 := r.blockStyle(c)
-				r, err := r.renderBlockNode(c, mc, contentBox.Shrink(bs2.Margin))
-				if err != nil {
-					return Rect{}, err
-				}
-
-				contentBox.Top = r.Bottom
-				contentBox.Top.Position += bottom(bs2.Margin) // TODO Collapse vertical margins
+				children = append(children, c)
 			}
 		}
 
-		boxBottom := contentBox.Top
+		collapseTop, collapseBottom := r.collapsibleEdges(n)
+		boxBottom, err := r.renderBlockNodes(children, mc, contentBox, collapseTop, collapseBottom)
+		if err != nil {
+			return Rect{}, err
+		}
 		boxBottom.Position += bottom(bs.Padding) + bottom(bs.Border)
 
 		return borderBox.ToRect(boxBottom), nil
 	}
 }
+
+// renderBlockNodes stacks block nodes vertically inside the contentBox
+// and returns the bottom of the last node including its margin.
+// The margins between adjacent nodes collapse into the larger one.
+// A node is moved to the next page to keep it with the next node or unbroken as specified by its BlockStyle,
+// and page breaks are inserted before and after nodes as specified by it.
+// If collapseTop or collapseBottom is true, the top margin of the first node or the bottom margin of the last node
+// is left out because it has collapsed into the margin of the parent (see collapsedMargins).
+func (r *Renderer) renderBlockNodes(nodes []ast.Node, mc MeasureContext, contentBox HalfBounds, collapseTop, collapseBottom bool) (VerticalCoord, error) {
+	prevMarginBottom := 0.0
+	for i, c := range nodes {
+		bs := r.blockStyle(c)
+		marginTop, marginBottom := r.collapsedMargins(c)
+
+		borderBox := contentBox.Shrink(horizontalSpacing(bs.Margin))
+		if i != 0 || !collapseTop {
+			borderBox.Top.Position += collapseMargins(prevMarginBottom, marginTop)
+		}
+		borderBox.Top = suppressMarginAtPageTop(mc, contentBox.Top, borderBox.Top)
+
+		if bs.PageBreakBefore {
+			borderBox.Top = nextPageTop(mc, borderBox.Top)
+		}
+		fits := false
+		if bs.KeepWithNext || bs.AvoidBreakInside {
+			keptBox := contentBox
+			keptBox.Top = borderBox.Top
+			top, ok, err := r.avoidPageBreak(nodes[i:], mc, keptBox)
+			if err != nil {
+				return VerticalCoord{}, err
+			}
+			borderBox.Top, fits = top, ok
+		}
+
+		fittingPage := r.fittingPage
+		if fits {
+			r.fittingPage = borderBox.Top.Page
+		}
+		rect, err := r.renderBlockNode(c, mc, borderBox)
+		r.fittingPage = fittingPage
+		if err != nil {
+			return VerticalCoord{}, err
+		}
+
+		contentBox.Top = rect.Bottom
+		prevMarginBottom = marginBottom
+
+		if bs.PageBreakAfter && r.hasFollowingContent(c) {
+			contentBox.Top = nextPageTop(mc, contentBox.Top)
+			prevMarginBottom = 0
+		}
+	}
+	if !collapseBottom {
+		contentBox.Top.Position += prevMarginBottom
+	}
+	return contentBox.Top, nil
+}
+
+// firstChildBorderBox returns the border box of the first child of the node
+// as placed by renderBlockNodes in the content box of the node.
+func (r *Renderer) firstChildBorderBox(n ast.Node, contentBox HalfBounds) HalfBounds {
+	bs := r.blockStyle(n.FirstChild())
+	borderBox := contentBox.Shrink(horizontalSpacing(bs.Margin))
+	if collapseTop, _ := r.collapsibleEdges(n); !collapseTop {
+		marginTop, _ := r.collapsedMargins(n.FirstChild())
+		borderBox.Top.Position += marginTop
+	}
+	return borderBox
+}
+
+// collapsibleEdges reports whether the top and bottom margins of the node collapse with those of its first and last child,
+// which is the case when the node has only block children and no border or padding separates them.
+func (r *Renderer) collapsibleEdges(n ast.Node) (bool, bool) {
+	switch n.(type) {
+	case *ast.Document, *xast.Table, *TableOfContentsEntry, *ast.CodeBlock, *ast.FencedCodeBlock, *xast.DefinitionList:
+		return false, false
+	}
+	if n.FirstChild() == nil {
+		return false, false
+	}
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		if c.Type() != ast.TypeBlock {
+			return false, false
+		}
+	}
+
+	bs := r.blockStyle(n)
+	return top(bs.Border) == 0 && top(bs.Padding) == 0, bottom(bs.Border) == 0 && bottom(bs.Padding) == 0
+}
+
+// collapsedMargins returns the top and bottom margins of the node
+// combined with the margins of the descendants that collapse through its edges.
+func (r *Renderer) collapsedMargins(n ast.Node) (float64, float64) {
+	return r.collapsedMargin(n, true), r.collapsedMargin(n, false)
+}
+
+// collapsedMargin returns the top or bottom margin of the node as collapsedMargins does.
+// It follows only the first or the last children, as a node that is both would otherwise be visited
+// twice at each level and the cost would grow exponentially with the nesting depth.
+func (r *Renderer) collapsedMargin(n ast.Node, isTop bool) float64 {
+	bs := r.blockStyle(n)
+	collapseTop, collapseBottom := r.collapsibleEdges(n)
+	if isTop {
+		if collapseTop {
+			return collapseMargins(top(bs.Margin), r.collapsedMargin(n.FirstChild(), true))
+		}
+		return top(bs.Margin)
+	}
+	if collapseBottom {
+		return collapseMargins(bottom(bs.Margin), r.collapsedMargin(n.LastChild(), false))
+	}
+	return bottom(bs.Margin)
+}
+
+// collapseMargins returns the margin that two adjoining margins collapse into:
+// the larger of the positive margins plus the smaller of the negative margins, as in CSS.
+func collapseMargins(a, b float64) float64 {
+	return math.Max(math.Max(a, b), 0) + math.Min(math.Min(a, b), 0)
+}
+
+// horizontalSpacing returns the left and right parts of the spacer.
+func horizontalSpacing(spacer Spacer) Spacing {
+	if spacer == nil {
+		return Spacing{}
+	}
+	l, _, r, _ := spacer.Space()
+	return Spacing{Left: l, Right: r}
+}
+
+// suppressMarginAtPageTop moves the top of a block to the top of the next page
+// when its top margin reaches the end of the page, and removes the top margin
+// of a block following a page break, so that no page starts with a blank gap.
+func suppressMarginAtPageTop(mc MeasureContext, beforeMargin, afterMargin VerticalCoord) VerticalCoord {
+	if _, pageBottom := mc.GetPageVerticalBounds(afterMargin.Page); afterMargin.Position >= pageBottom {
+		afterMargin.Page++
+		afterMargin.Position, _ = mc.GetPageVerticalBounds(afterMargin.Page)
+		return afterMargin
+	}
+	if pageTop, _ := mc.GetPageVerticalBounds(beforeMargin.Page); beforeMargin.Page > 1 && beforeMargin.Position <= pageTop {
+		return beforeMargin
+	}
+	return afterMargin
+}
@@ -4,45 +4,152 @@ import (
 	"fmt"
 	"image/color"
 	"io"
+	"reflect"
 
 	"github.com/jung-kurt/gofpdf"
 	"github.com/yuin/goldmark/ast"
+	xast "github.com/yuin/goldmark/extension/ast"
 	"github.com/yuin/goldmark/renderer"
+	"golang.org/x/image/font/sfnt"
 )
 
 type PDFProvider func() *gofpdf.Fpdf
 
 type Renderer struct {
-	source      []byte
-	pdfProvider PDFProvider
-	styler      Styler
-	imageLoader ImageLoader
+	source        []byte
+	pdfProvider   PDFProvider
+	styler        Styler
+	imageLoader   ImageLoader
+	highlighter   Highlighter
+	pageDecorator PageDecorator
+	metadata      Metadata
+	outline       bool
+	tocPlacement  TableOfContentsPlacement
+	headingPages  map[*ast.Heading]int
+
+	fontFamilies []fontFamily
+	loadedFonts  map[string][4]loadedFont // fonts of fontFamilies by lowercased family
+	fontData     map[string][]byte        // TrueType data by lowercased family
+	fonts        map[string]*sfnt.Font    // parsed fontData
+	sfntBuffer   sfnt.Buffer
+
+	repeatedTableHeader repeatedTableHeader // the header row of the table being rendered, repeated on the following pages
+	splitTableRow       splitTableRow       // the insets of the table row being rendered, reserved where it is split
+
+	footnotePlacement  FootnotePlacement
+	footnotes          map[int]*xast.Footnote
+	footnoteList       *xast.FootnoteList
+	footnotePlacements map[*xast.Footnote]footnotePlacement // nil unless footnotes are being placed at the bottom of pages
+	footnoteBounds     HalfBounds                           // the content box of the footnote area
+
+	fittingPage int // the page that the block being rendered is known to fit in, where no page breaks need to be avoided
 }
 
+// maxRenderPasses limits the number of passes made to resolve the page numbers in the table of contents.
+const maxRenderPasses = 4
+
 func (r *Renderer) Render(w io.Writer, source []byte, n ast.Node) error {
 	if n.Type() != ast.TypeDocument {
 		return fmt.Errorf("called with a node other than Document: %s", n.Kind())
 	}
 
+	r.source = source
+	r.headingPages = map[*ast.Heading]int{}
+	if err := r.loadFontFamilies(); err != nil {
+		return err
+	}
+	if err := r.loadFonts(); err != nil {
+		return err
+	}
+
+	hasTOC, removeTOC, err := r.insertTableOfContents(n, r.tocPlacement)
+	if err != nil {
+		return err
+	}
+	defer removeTOC()
+	if err := r.collectFootnotes(n); err != nil {
+		return err
+	}
+
+	// Since the table of contents refers to the pages of the headings after it,
+	// render the document again until the page numbers no longer change.
+	var fpdf *gofpdf.Fpdf
+	for pass := 1; ; pass++ {
+		prevHeadingPages := map[*ast.Heading]int{}
+		for h, p := range r.headingPages {
+			prevHeadingPages[h] = p
+		}
+
+		fpdf, err = r.renderDocument(n)
+		if err != nil {
+			return err
+		}
+
+		if !hasTOC || pass == maxRenderPasses || reflect.DeepEqual(prevHeadingPages, r.headingPages) {
+			break
+		}
+	}
+
+	r.metadata.merge(n).apply(fpdf)
+
+	fpdf.SetPage(fpdf.PageCount()) // Since fpdf only outputs up to the current page
+	return fpdf.Output(w)
+}
+
+// renderDocument renders the document node to a new PDF.
+func (r *Renderer) renderDocument(n ast.Node) (*gofpdf.Fpdf, error) {
 	fpdf := r.pdfProvider()
+	r.addFonts(fpdf)
+	if err := r.checkFontFamilies(fpdf); err != nil {
+		return nil, err
+	}
 	fpdf.AddPage()
 
-	r.source = source
-
-	lm, tm, rm, _ := fpdf.GetMargins()
+	lm, _, rm, _ := fpdf.GetMargins()
 	pw, _ := fpdf.GetPageSize()
 
+	rc := &renderContextImpl{fpdf: fpdf, reserved: r.reservedHeights, syntheticStyle: r.syntheticStyle}
+	tm, _ := rc.GetPageVerticalBounds(1)
+
 	bounds := HalfBounds{
 		Left:  lm,
 		Right: pw - rm,
 		Top:   VerticalCoord{Page: 1, Position: tm},
 	}
-	if _, err := r.renderBlockNode(n, &renderContextImpl{fpdf: fpdf}, bounds); err != nil {
-		return err
+
+	r.footnotePlacements = nil
+	if r.footnotePlacement == FootnotesAtPageBottom && r.footnoteList != nil {
+		bs := r.blockStyle(r.footnoteList)
+		r.footnotePlacements = map[*xast.Footnote]footnotePlacement{}
+		r.footnoteBounds = bounds.Shrink(bs.Margin, bs.Border, bs.Padding)
 	}
 
-	fpdf.SetPage(fpdf.PageCount()) // Since fpdf only outputs up to the current page
-	return fpdf.Output(w)
+	if _, err := r.renderBlockNode(n, rc, bounds); err != nil {
+		return nil, err
+	}
+	if err := r.renderPageFootnotes(rc); err != nil {
+		return nil, err
+	}
+	rc.resolveLinks()
+
+	// Decorate pages after the body has been laid out so that the total page count is known
+	if r.pageDecorator != nil {
+		totalPages := fpdf.PageCount()
+		for page := 1; page <= totalPages; page++ {
+			top, bottom := rc.GetPageVerticalBounds(page)
+			body := Rect{
+				Left:   lm,
+				Right:  pw - rm,
+				Top:    VerticalCoord{Page: page, Position: top},
+				Bottom: VerticalCoord{Page: page, Position: bottom},
+			}
+			if err := r.pageDecorator.Decorate(rc, page, totalPages, body); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return fpdf, nil
 }
 
 func (r *Renderer) blockStyleTextFormat(n ast.Node) (BlockStyle, TextFormat) {
@@ -56,6 +163,11 @@ func (r *Renderer) blockStyleTextFormat(n ast.Node) (BlockStyle, TextFormat) {
 	for i := range ancestors {
 		bs, tf = r.styler.Style(ancestors[len(ancestors)-i-1], tf)
 	}
+	if n, ok := n.(*ast.HTMLBlock); ok {
+		before, after := r.pageBreakMarker(n)
+		bs.PageBreakBefore = bs.PageBreakBefore || before
+		bs.PageBreakAfter = bs.PageBreakAfter || after
+	}
 	return bs, tf
 }
 
@@ -74,7 +186,23 @@ func (r *Renderer) AddOptions(options ...renderer.Option) {}
 
 type Option func(*Renderer)
 
+// New returns a new Renderer.
+// If the options are invalid, for example when a font cannot be loaded, Render returns the error.
 func New(options ...Option) renderer.Renderer {
+	return newRenderer(options...)
+}
+
+// NewRenderer is like New but returns the error in the options immediately,
+// including a font family referenced by the DefaultStyler that is not available.
+func NewRenderer(options ...Option) (*Renderer, error) {
+	r := newRenderer(options...)
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func newRenderer(options ...Option) *Renderer {
 	r := &Renderer{
 		pdfProvider: func() *gofpdf.Fpdf { return gofpdf.New(gofpdf.OrientationPortrait, "pt", "A4", ".") },
 		styler:      &DefaultStyler{FontFamily: "Arial", FontSize: 12, Color: color.Black},
@@ -86,6 +214,19 @@ func New(options ...Option) renderer.Renderer {
 	return r
 }
 
+func (r *Renderer) validate() error {
+	if err := r.loadFontFamilies(); err != nil {
+		return err
+	}
+	if err := r.loadFonts(); err != nil {
+		return err
+	}
+
+	fpdf := r.pdfProvider()
+	r.addFonts(fpdf)
+	return r.checkFontFamilies(fpdf)
+}
+
 func WithPDFProvider(pdfProvider PDFProvider) Option {
 	return func(r *Renderer) { r.pdfProvider = pdfProvider }
 }
@@ -97,3 +238,37 @@ func WithStyler(styler Styler) Option {
 func WithImageLoader(imageLoader ImageLoader) Option {
 	return func(r *Renderer) { r.imageLoader = imageLoader }
 }
+
+// WithHighlighter sets a Highlighter for the syntax highlighting of fenced code blocks.
+// Code blocks are not highlighted by default. The highlight package provides a Highlighter using chroma.
+func WithHighlighter(highlighter Highlighter) Option {
+	return func(r *Renderer) { r.highlighter = highlighter }
+}
+
+// WithMetadata sets the document information of the PDF.
+// Values found in the front matter of the document take precedence over it.
+func WithMetadata(metadata Metadata) Option {
+	return func(r *Renderer) { r.metadata = metadata }
+}
+
+// WithPageDecorator sets a PageDecorator that draws headers and footers on every page.
+func WithPageDecorator(pageDecorator PageDecorator) Option {
+	return func(r *Renderer) { r.pageDecorator = pageDecorator }
+}
+
+// WithFootnotePlacement sets where the footnotes of the Footnote extension are placed.
+func WithFootnotePlacement(placement FootnotePlacement) Option {
+	return func(r *Renderer) { r.footnotePlacement = placement }
+}
+
+// WithTableOfContents makes the Renderer insert a table of contents with page numbers into the document.
+// The TableOfContents node is inserted into the AST passed to Render only while it is rendered,
+// so the AST is left as it was when Render returns.
+func WithTableOfContents(placement TableOfContentsPlacement) Option {
+	return func(r *Renderer) { r.tocPlacement = placement }
+}
+
+// WithOutline makes the Renderer build a document outline (bookmarks) from the headings.
+func WithOutline() Option {
+	return func(r *Renderer) { r.outline = true }
+}
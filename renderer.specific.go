@@ -3,6 +3,9 @@ package goldpdf
 import (
 	"fmt"
 	"image/color"
+	"math"
+	"strconv"
+	"strings"
 
 	"github.com/yuin/goldmark/ast"
 	xast "github.com/yuin/goldmark/extension/ast"
@@ -21,22 +24,38 @@ func (r *Renderer) renderListItem(n ast.Node, mc MeasureContext, borderBox HalfB
 		// ListItemの最初のブロックノード
 		n2 := n.FirstChild()
 		bs2 := r.blockStyle(n2)
-		contentBox2 := contentBox.Shrink(bs2.Margin, bs2.Border, bs2.Padding)
+		contentBox2 := r.firstChildBorderBox(n, contentBox).Shrink(bs2.Border, bs2.Padding)
 
-		elements, err := r.getFlowElements(n2)
+		baseline, err := r.firstLineBaseline(n2, mc, contentBox2)
 		if err != nil {
 			return err
 		}
-		_, h := elements[0].size(mc)
+		baseline += contentBox2.Top.Position
+		fontSize := r.textFormat(n2).FontSize
 
-		if list, ok := n.Parent().(*ast.List); ok && list.IsOrdered() {
+		if checkBox := taskCheckBox(n2); checkBox != nil {
+			bs3, tf3 := r.blockStyleTextFormat(checkBox)
+			size := tf3.FontSize
+			x := contentBox2.Left - 10 - size/2
+			y := baseline - fontSize*0.35 - size/2 // centered at the middle of lowercase letters
+			rect := Rect{
+				Left:   x,
+				Right:  x + size,
+				Top:    VerticalCoord{Page: contentBox.Top.Page, Position: y},
+				Bottom: VerticalCoord{Page: contentBox.Top.Page, Position: y + size},
+			}
+			rc.DrawBox(rect, bs3.BackgroundColor, bs3.Border)
+			if rc, ok := rc.(*renderContextImpl); ok && checkBox.IsChecked {
+				rc.DrawCheckMark(rect.Shrink(bs3.Border, bs3.Padding), tf3.Color)
+			}
+		} else if list, ok := n.Parent().(*ast.List); ok && list.IsOrdered() {
 			ts := &TextElement{
 				Format: r.textFormat(n),
 				Text:   fmt.Sprintf("%d.", countPrevSiblings(n)+1),
 			}
-			rc.DrawText(contentBox.Top.Page, contentBox2.Left-15, contentBox2.Top.Position, ts)
+			rc.DrawText(contentBox.Top.Page, contentBox2.Left-15, baseline-ts.Format.FontSize, ts)
 		} else {
-			rc.DrawBullet(contentBox.Top.Page, contentBox2.Left-10, contentBox2.Top.Position+h/2, color.Black, 2)
+			rc.DrawBullet(contentBox.Top.Page, contentBox2.Left-10, baseline-fontSize*0.35, color.Black, 2)
 		}
 
 		return nil
@@ -52,6 +71,7 @@ func (r *Renderer) renderTable(n *xast.Table, mc MeasureContext, borderBox HalfB
 	bs := r.blockStyle(n)
 
 	err := mc.GetRenderContext(func(rc RenderContext) error {
+		defer r.snapshotFootnotePlacements()()
 		rect, err := r.renderTable(n, mc, borderBox)
 		if err != nil {
 			return err
@@ -74,16 +94,49 @@ func (r *Renderer) renderTable(n *xast.Table, mc MeasureContext, borderBox HalfB
 	}
 
 	contentBox := borderBox.Shrink(bs.Border, bs.Padding)
+	var header *xast.TableHeader
 	for row := n.FirstChild(); row != nil; row = row.NextSibling() {
 		switch row := row.(type) {
 		case *xast.TableHeader, *xast.TableRow:
-			bs := r.blockStyle(row)
-			rowRect, err := r.renderTableRow(row, mc, contentBox.Shrink(bs.Margin), columnContentWidth)
+			rowBS := r.blockStyle(row)
+			rowBox := contentBox.Shrink(rowBS.Margin)
+			height, err := r.tableRowKeptHeight(row, mc, rowBox, columnContentWidth, bs.TableRowBreak)
+			if err != nil {
+				return Rect{}, err
+			}
+			rowBox.Top = breakBefore(mc, rowBox.Top, height)
+
+			rowRect, err := r.renderTableRow(row, mc, rowBox, columnContentWidth)
 			if err != nil {
 				return Rect{}, err
 			}
 			contentBox.Top = rowRect.Bottom
-			contentBox.Top.Position += bottom(bs.Margin)
+			contentBox.Top.Position += bottom(rowBS.Margin)
+
+			if row, ok := row.(*xast.TableHeader); ok && bs.RepeatTableHeader {
+				height, err := r.repeatedTableHeaderHeight(n, row, mc, contentBox, columnContentWidth)
+				if err != nil {
+					return Rect{}, err
+				}
+
+				// Leave room for the header at the top of the following pages while the rows are rendered
+				header = row
+				saved := r.repeatedTableHeader
+				r.repeatedTableHeader = repeatedTableHeader{page: rowRect.Bottom.Page, height: height}
+				defer func() { r.repeatedTableHeader = saved }()
+			}
+		}
+	}
+
+	if header != nil {
+		for page := r.repeatedTableHeader.page + 1; page <= contentBox.Top.Page; page++ {
+			headerBox := contentBox
+			headerBox.Top.Page = page
+			headerBox.Top.Position, _ = mc.GetPageVerticalBounds(page)
+			headerBox.Top.Position -= r.repeatedTableHeader.height
+			if _, err := r.renderRepeatedTableHeader(n, header, mc, headerBox, columnContentWidth); err != nil {
+				return Rect{}, err
+			}
 		}
 	}
 
@@ -93,6 +146,43 @@ func (r *Renderer) renderTable(n *xast.Table, mc MeasureContext, borderBox HalfB
 	return borderBox.ToRect(boxBottom), nil
 }
 
+// repeatedTableHeader is the header row of a table repeated at the top of the pages after page.
+type repeatedTableHeader struct {
+	page   int
+	height float64 // the height of the header row and the caption including their margins
+}
+
+// repeatedTableHeaderHeight returns the height of the header row repeated with the continuation caption.
+func (r *Renderer) repeatedTableHeaderHeight(n *xast.Table, header *xast.TableHeader, mc MeasureContext, contentBox HalfBounds, columnContentWidth []float64) (float64, error) {
+	contentBox.Top = VerticalCoord{Page: 1}
+	bottom, err := r.renderRepeatedTableHeader(n, header, unpaginatedContext{mc}, contentBox, columnContentWidth)
+	if err != nil {
+		return 0, err
+	}
+	return bottom.Position, nil
+}
+
+// renderRepeatedTableHeader draws the continuation caption and the header row of the table from the top of the contentBox
+// and returns the bottom of the header row including its margin.
+func (r *Renderer) renderRepeatedTableHeader(n *xast.Table, header *xast.TableHeader, mc MeasureContext, contentBox HalfBounds, columnContentWidth []float64) (VerticalCoord, error) {
+	if caption := r.blockStyle(n).TableContinuationCaption; caption != "" {
+		elements := r.applyFontFallback([]InlineElement{&TextElement{Format: r.textFormat(n), Text: caption}})
+		rect, err := r.renderInlineElements(elements, mc, contentBox, BlockStyle{})
+		if err != nil {
+			return VerticalCoord{}, err
+		}
+		contentBox.Top = rect.Bottom
+	}
+
+	bs := r.blockStyle(header)
+	rect, err := r.renderTableRow(header, mc, contentBox.Shrink(bs.Margin), columnContentWidth)
+	if err != nil {
+		return VerticalCoord{}, err
+	}
+	rect.Bottom.Position += bottom(bs.Margin)
+	return rect.Bottom, nil
+}
+
 func (r *Renderer) renderTableRow(n ast.Node, mc MeasureContext, borderBox HalfBounds, columnContentWidth []float64) (Rect, error) {
 	switch n.Kind() {
 	case xast.KindTableHeader, xast.KindTableRow:
@@ -101,9 +191,11 @@ func (r *Renderer) renderTableRow(n ast.Node, mc MeasureContext, borderBox HalfB
 	}
 
 	bs := r.blockStyle(n)
-	var borderBoxBottom []VerticalCoord
+	contentBox := borderBox.Shrink(bs.Border, bs.Padding)
+	cellBoxes := r.tableCellBorderBoxes(n, contentBox, columnContentWidth)
 
 	err := mc.GetRenderContext(func(rc RenderContext) error {
+		defer r.snapshotFootnotePlacements()()
 		rowRect, err := r.renderTableRow(n, mc, borderBox, columnContentWidth)
 		if err != nil {
 			return err
@@ -111,34 +203,233 @@ func (r *Renderer) renderTableRow(n ast.Node, mc MeasureContext, borderBox HalfB
 
 		rc.DrawBox(rowRect, bs.BackgroundColor, bs.Border)
 
+		// The cells are as high as the row
 		rowRect.Bottom.Position -= bottom(bs.Border) + bottom(bs.Padding)
-		borderBoxBottom = []VerticalCoord{rowRect.Bottom} // ここで各テーブルセルの高さを行と一致させる
+		for i, cell := 0, n.FirstChild(); cell != nil; i, cell = i+1, cell.NextSibling() {
+			bs := r.blockStyle(cell)
+			rc.DrawBox(cellBoxes[i].ToRect(rowRect.Bottom), bs.BackgroundColor, bs.Border)
+		}
 		return nil
 	})
 	if err != nil {
 		return Rect{}, err
 	}
 
-	contentBox := borderBox.ToRect(borderBox.Top).Shrink(bs.Border, bs.Padding)
+	// Leave room for the borders and paddings of the cells where the row is split across pages
+	saved := r.splitTableRow
+	r.splitTableRow = r.tableRowInsets(n, contentBox.Top.Page)
+	defer func() { r.splitTableRow = saved }()
 
+	boxBottom := contentBox.Top
+	for i, cell := 0, n.FirstChild(); cell != nil; i, cell = i+1, cell.NextSibling() {
+		cellRect, err := r.renderBlockContents(cell, mc, cellBoxes[i])
+		if err != nil {
+			return Rect{}, err
+		}
+		if boxBottom.LessThan(cellRect.Bottom) {
+			boxBottom = cellRect.Bottom
+		}
+	}
+
+	boxBottom.Position += bottom(bs.Border) + bottom(bs.Padding)
+	return borderBox.ToRect(boxBottom), nil
+}
+
+// tableCellBorderBoxes returns the border boxes of the cells of the row placed side by side in the contentBox of the row.
+func (r *Renderer) tableCellBorderBoxes(n ast.Node, contentBox HalfBounds, columnContentWidth []float64) []HalfBounds {
+	boxes := []HalfBounds{}
 	for cell := n.FirstChild(); cell != nil; cell = cell.NextSibling() {
 		bs := r.blockStyle(cell)
 		contentBox.Left += bs.Margin.Left
 		contentBox.Right = contentBox.Left + columnContentWidth[countPrevSiblings(cell)] + horizontal(bs.Border) + horizontal(bs.Padding)
+		boxes = append(boxes, contentBox)
+		contentBox.Left = contentBox.Right + bs.Margin.Right
+	}
+	return boxes
+}
+
+// splitTableRow is the heights reserved at the top and bottom of the pages where a table row is split,
+// so that the content of the cells continues inside their borders and paddings.
+type splitTableRow struct {
+	page        int // the first page of the row
+	top, bottom float64
+}
+
+// tableRowInsets returns the heights reserved for the table row starting on the page.
+func (r *Renderer) tableRowInsets(n ast.Node, page int) splitTableRow {
+	bs := r.blockStyle(n)
+	insets := splitTableRow{page: page}
+	for cell := n.FirstChild(); cell != nil; cell = cell.NextSibling() {
+		bs := r.blockStyle(cell)
+		insets.top = math.Max(insets.top, top(bs.Border)+top(bs.Padding))
+		insets.bottom = math.Max(insets.bottom, bottom(bs.Border)+bottom(bs.Padding))
+	}
+	insets.bottom += bottom(bs.Border) + bottom(bs.Padding)
+	return insets
+}
+
+// tableRowKeptHeight returns the height of the part of the table row placed in the borderBox
+// that must fit on a page according to the policy: the whole row, or the first line of its cells.
+func (r *Renderer) tableRowKeptHeight(n ast.Node, mc MeasureContext, borderBox HalfBounds, columnContentWidth []float64, policy TableRowBreak) (float64, error) {
+	if policy == TableRowBreakKeep {
+		defer r.snapshotFootnotePlacements()()
+		rect, err := r.renderTableRow(n, unpaginatedContext{mc}, borderBox, columnContentWidth)
+		if err != nil {
+			return 0, err
+		}
+		return rect.Bottom.Position - rect.Top.Position, nil
+	}
 
-		cellRect, err := r.renderGenericBlockNode(cell, mc, contentBox.ToHalfBounds(), borderBoxBottom...)
+	bs := r.blockStyle(n)
+	contentBox := borderBox.Shrink(bs.Border, bs.Padding)
+	height := 0.0
+	for i, cell := 0, n.FirstChild(); cell != nil; i, cell = i+1, cell.NextSibling() {
+		cellBox := r.tableCellBorderBoxes(n, contentBox, columnContentWidth)[i]
+		lineHeight, err := r.firstLinesHeight(cell, mc, cellBox, 1)
+		if err != nil {
+			return 0, err
+		}
+		cellBS := r.blockStyle(cell)
+		height = math.Max(height, lineHeight+bottom(cellBS.Border)+bottom(cellBS.Padding))
+	}
+	return height + top(bs.Border) + top(bs.Padding) + bottom(bs.Border) + bottom(bs.Padding), nil
+}
+
+// renderDefinitionList draws a definition list.
+// If DefinitionTermWidth is specified, the terms are placed in a column of that width beside their descriptions.
+func (r *Renderer) renderDefinitionList(n *xast.DefinitionList, mc MeasureContext, borderBox HalfBounds) (Rect, error) {
+	bs := r.blockStyle(n)
+	if bs.DefinitionTermWidth == 0 {
+		return r.renderGenericBlockNode(n, mc, borderBox)
+	}
+
+	err := mc.GetRenderContext(func(rc RenderContext) error {
+		defer r.snapshotFootnotePlacements()()
+		rect, err := r.renderDefinitionList(n, mc, borderBox)
+		if err != nil {
+			return err
+		}
+		rc.DrawBox(rect, bs.BackgroundColor, bs.Border)
+		return nil
+	})
+	if err != nil {
+		return Rect{}, err
+	}
+
+	contentBox := borderBox.Shrink(bs.Border, bs.Padding)
+	termBox := contentBox
+	termBox.Right = termBox.Left + bs.DefinitionTermWidth
+	descriptionBox := contentBox
+	descriptionBox.Left = termBox.Right
+
+	// Each group of consecutive terms and the following descriptions forms a row
+	for c := n.FirstChild(); c != nil; {
+		terms, descriptions := []ast.Node{}, []ast.Node{}
+		for ; c != nil && c.Kind() == xast.KindDefinitionTerm; c = c.NextSibling() {
+			terms = append(terms, c)
+		}
+		for ; c != nil && c.Kind() != xast.KindDefinitionTerm; c = c.NextSibling() {
+			descriptions = append(descriptions, c)
+		}
+
+		termBox.Top = contentBox.Top
+		termBottom, err := r.renderBlockNodes(terms, mc, termBox, false, false)
 		if err != nil {
 			return Rect{}, err
 		}
 
-		contentBox.Left = contentBox.Right + bs.Margin.Right
-		if contentBox.Bottom.LessThan(cellRect.Bottom) {
-			contentBox.Bottom = cellRect.Bottom
+		descriptionBox.Top = contentBox.Top
+		descriptionBottom, err := r.renderBlockNodes(descriptions, mc, descriptionBox, false, false)
+		if err != nil {
+			return Rect{}, err
+		}
+
+		contentBox.Top = termBottom
+		if contentBox.Top.LessThan(descriptionBottom) {
+			contentBox.Top = descriptionBottom
+		}
+	}
+
+	boxBottom := contentBox.Top
+	boxBottom.Position += bottom(bs.Padding) + bottom(bs.Border)
+	return borderBox.ToRect(boxBottom), nil
+}
+
+// renderTableOfContentsEntry draws the title of the heading followed by dot leaders and its page number.
+func (r *Renderer) renderTableOfContentsEntry(n *TableOfContentsEntry, mc MeasureContext, borderBox HalfBounds) (Rect, error) {
+	bs := r.blockStyle(n)
+
+	err := mc.GetRenderContext(func(rc RenderContext) error {
+		defer r.snapshotFootnotePlacements()()
+		rect, err := r.renderTableOfContentsEntry(n, mc, borderBox)
+		if err != nil {
+			return err
+		}
+		rc.DrawBox(rect, bs.BackgroundColor, bs.Border)
+		return nil
+	})
+	if err != nil {
+		return Rect{}, err
+	}
+
+	tf := r.textFormat(n)
+	link := ""
+	if id := nodeID(n.Heading); id != "" {
+		link = "#" + id
+	}
+
+	title := r.applyFontFallback([]InlineElement{&TextElement{Format: tf, Text: string(n.Heading.Text(r.source)), Link: link}})
+	pageNumber := &TextElement{Format: tf, Link: link}
+	if page, ok := r.headingPages[n.Heading]; ok {
+		pageNumber.Text = strconv.Itoa(page)
+	}
+
+	// The width of the page number column does not depend on the actual page numbers
+	// so that the layout of the table of contents does not change between passes.
+	contentBox := borderBox.Shrink(bs.Border, bs.Padding)
+	titleBox := contentBox
+	titleBox.Right -= mc.GetTextWidth(&TextElement{Format: tf, Text: " 0000"})
+
+	rect, err := r.renderInlineElements(title, mc, titleBox, bs)
+	if err != nil {
+		return Rect{}, err
+	}
+
+	err = mc.GetRenderContext(func(rc RenderContext) error {
+		lines := getLineBreaker(bs)(mc, titleBox.Width(), title, bs)
+		lastLine := lines[len(lines)-1]
+		lastLineWidth, lineHeight := getLineSize(mc, lastLine)
+		above, _ := pageNumber.metrics(mc)
+		y := rect.Bottom.Position - lineHeight + getLineBaseline(mc, lastLine) - above // drawn through drawTo to add the links
+
+		numberX := contentBox.Right - mc.GetTextWidth(pageNumber)
+		pageNumber.drawTo(rc, rect.Bottom.Page, numberX, y)
+
+		gap := mc.GetTextWidth(&TextElement{Format: tf, Text: " "})
+		if dotWidth := mc.GetTextWidth(&TextElement{Format: tf, Text: "."}); dotWidth > 0 {
+			count := int(math.Floor((numberX - gap - (titleBox.Left + lastLineWidth + gap)) / dotWidth))
+			if count > 0 {
+				leader := &TextElement{Format: tf, Text: strings.Repeat(".", count), Link: link}
+				leader.drawTo(rc, rect.Bottom.Page, numberX-gap-mc.GetTextWidth(leader), y)
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return Rect{}, err
 	}
 
-	contentBox.Bottom.Position += bottom(bs.Border) + bottom(bs.Padding)
-	return borderBox.ToRect(contentBox.Bottom), nil
+	rect.Right = contentBox.Right
+	return rect.Expand(bs.Border, bs.Padding), nil
+}
+
+// taskCheckBox returns the check box of a GFM task list item, or nil.
+func taskCheckBox(n ast.Node) *xast.TaskCheckBox {
+	if n == nil {
+		return nil
+	}
+	checkBox, _ := n.FirstChild().(*xast.TaskCheckBox)
+	return checkBox
 }
 
 func countPrevSiblings(n ast.Node) int {
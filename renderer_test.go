@@ -0,0 +1,196 @@
+package goldpdf
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+)
+
+func TestCollapseMargins(t *testing.T) {
+	tests := []struct {
+		a, b, want float64
+	}{
+		{6, 6, 6},
+		{6, 10, 10},
+		{10, -4, 6},
+		{-4, -8, -8},
+		{0, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := collapseMargins(tt.a, tt.b); got != tt.want {
+			t.Errorf("collapseMargins(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCollapsedMargins(t *testing.T) {
+	source := []byte("> # Heading\n>\n> paragraph\n\n```\ncode\n```\n")
+	doc := goldmark.New().Parser().Parse(text.NewReader(source))
+	r := newRenderer()
+	r.source = source
+
+	// The margins of the heading and the paragraph collapse through the blockquote, which has no top or bottom border
+	quote := doc.FirstChild()
+	headingMargin := r.blockStyle(quote.FirstChild()).Margin.Top
+	if top, bottom := r.collapsedMargins(quote); top != headingMargin || bottom != 6 {
+		t.Errorf("collapsedMargins(blockquote) = %v, %v, want %v, 6", top, bottom, headingMargin)
+	}
+
+	// The padding of the code block keeps its margins apart from its content
+	code := quote.NextSibling()
+	if top, bottom := r.collapsedMargins(code); top != 10 || bottom != 10 {
+		t.Errorf("collapsedMargins(code block) = %v, %v, want 10, 10", top, bottom)
+	}
+}
+
+func TestRepeatTableHeader(t *testing.T) {
+	source := "| Name | Value |\n|---|---|\n"
+	for i := 0; i < 60; i++ {
+		source += fmt.Sprintf("| row%d | %d |\n", i, i)
+	}
+
+	_, output := renderMarkdown(t, source, WithStyler(&DefaultStyler{FontFamily: "Arial", FontSize: 12, Color: color.Black, TableContinuationCaption: "continued"}))
+
+	// The table spans three pages and the header is repeated with the caption on the last two
+	if got := strings.Count(output, "(Name)"); got != 3 {
+		t.Errorf("the header is drawn %d times, want 3", got)
+	}
+	if got := strings.Count(output, "(continued)"); got != 2 {
+		t.Errorf("the caption is drawn %d times, want 2", got)
+	}
+}
+
+func TestPageCount(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{"heading", "# Title\n\ntext\n"},
+		{"list", "- item\n"},
+		{"code block", "```\ncode\n```\n"},
+		{"table", "| A | B |\n|---|---|\n| 1 | 2 |\n| 3 | 4 |\n"},
+	}
+	for _, tt := range tests {
+		fpdf, _ := renderMarkdown(t, tt.source)
+		if got := fpdf.PageCount(); got != 1 {
+			t.Errorf("%s: PageCount() = %d, want 1", tt.name, got)
+		}
+	}
+
+	// The pages of a landscape PDF are filled up to their bottom margin and no further
+	var fpdf *gofpdf.Fpdf
+	_, output := renderMarkdown(t, strings.Repeat("line\n\n", 60), WithPDFProvider(func() *gofpdf.Fpdf {
+		fpdf = gofpdf.New(gofpdf.OrientationLandscape, "pt", "A4", ".")
+		fpdf.SetCompression(false)
+		return fpdf
+	}))
+	_, _, _, bm := fpdf.GetMargins()
+	pages := pageContents(output)
+	for i, contents := range pages {
+		lowest := math.Inf(1)
+		for _, m := range regexp.MustCompile(`BT [\d.]+ (-?[\d.]+) Td \(line\) Tj`).FindAllStringSubmatch(contents, -1) {
+			y, _ := strconv.ParseFloat(m[1], 64)
+			lowest = math.Min(lowest, y)
+		}
+		if lowest < bm {
+			t.Errorf("landscape: the text on page %d is drawn at %v, below the bottom margin", i+1, lowest)
+		}
+		if i != len(pages)-1 && lowest > bm+40 {
+			t.Errorf("landscape: page %d ends at %v, leaving the bottom of the page empty", i+1, lowest)
+		}
+	}
+	if len(pages) < 2 {
+		t.Errorf("landscape: the text is laid out on %d pages, want at least 2", len(pages))
+	}
+}
+
+// renderMarkdown renders the markdown source to an uncompressed PDF
+// and returns the PDF along with its output.
+func renderMarkdown(t *testing.T, source string, options ...Option) (*gofpdf.Fpdf, string) {
+	t.Helper()
+
+	var fpdf *gofpdf.Fpdf
+	options = append([]Option{WithPDFProvider(func() *gofpdf.Fpdf {
+		fpdf = gofpdf.New(gofpdf.OrientationPortrait, "pt", "A4", ".")
+		fpdf.SetCompression(false)
+		return fpdf
+	})}, options...)
+
+	md := goldmark.New(
+		goldmark.WithExtensions(extension.GFM, extension.DefinitionList, extension.Footnote),
+		goldmark.WithRenderer(New(options...)),
+	)
+
+	buf := &bytes.Buffer{}
+	if err := md.Convert([]byte(source), buf); err != nil {
+		t.Fatal(err)
+	}
+	return fpdf, buf.String()
+}
+
+// pageContents splits the uncompressed PDF output into the page objects and their contents, in page order.
+func pageContents(output string) []string {
+	return strings.Split(output, "<</Type /Page\n")[1:]
+}
+
+func TestTaskListItem(t *testing.T) {
+	// The check mark is the only path drawn with round caps
+	_, output := renderMarkdown(t, "- [x] done\n- [ ] todo\n- [x] done too\n")
+	if got := strings.Count(output, "\n1 J\n"); got != 2 {
+		t.Errorf("%d check marks are drawn, want 2", got)
+	}
+	if strings.Contains(output, "([x]") || strings.Contains(output, "([ ]") {
+		t.Errorf("the check box is drawn as text")
+	}
+	if !strings.Contains(output, "(done) Tj") || !strings.Contains(output, "(todo) Tj") {
+		t.Errorf("the text of the task list items is not drawn")
+	}
+
+	if _, output := renderMarkdown(t, "- item\n"); strings.Contains(output, "\n1 J\n") {
+		t.Errorf("a check mark is drawn for a list item without a check box")
+	}
+}
+
+func TestDefinitionList(t *testing.T) {
+	source := "Term\n: Description\n\nNext\n: Another\n"
+	position := func(output, text string) (float64, float64) {
+		m := regexp.MustCompile(`BT ([\d.]+) ([\d.]+) Td \(` + text + `\) Tj`).FindStringSubmatch(output)
+		if m == nil {
+			t.Fatalf("%s is not drawn", text)
+		}
+		x, _ := strconv.ParseFloat(m[1], 64)
+		y, _ := strconv.ParseFloat(m[2], 64)
+		return x, y
+	}
+	near := func(a, b float64) bool { return math.Abs(a-b) < 0.01 }
+
+	// The descriptions are placed below their terms
+	_, output := renderMarkdown(t, source)
+	termX, termY := position(output, "Term")
+	descriptionX, descriptionY := position(output, "Description")
+	if !near(descriptionX, termX+24) || descriptionY >= termY {
+		t.Errorf("the description is drawn at %v, %v for the term at %v, %v", descriptionX, descriptionY, termX, termY)
+	}
+
+	// The descriptions are placed beside their terms in a column of DefinitionTermWidth
+	_, output = renderMarkdown(t, source, WithStyler(&DefaultStyler{FontFamily: "Arial", FontSize: 12, Color: color.Black, DefinitionTermWidth: 100}))
+	termX, termY = position(output, "Term")
+	descriptionX, descriptionY = position(output, "Description")
+	if !near(descriptionX, termX+100+24) || !near(descriptionY, termY) {
+		t.Errorf("the description is drawn at %v, %v for the term at %v, %v", descriptionX, descriptionY, termX, termY)
+	}
+	nextX, nextY := position(output, "Next")
+	if !near(nextX, termX) || nextY >= descriptionY {
+		t.Errorf("the next term is drawn at %v, %v after the description at %v, %v", nextX, nextY, descriptionX, descriptionY)
+	}
+}
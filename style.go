@@ -15,18 +15,137 @@ type BlockStyle struct {
 	Border          Border
 	TextAlign       xast.Alignment
 	TableLayout     TableLayout
+	// ColumnWidths constrains the content widths of the columns of a table laid out by
+	// TableLayoutAutoFilled or TableLayoutAutoCompact, in the order of the columns.
+	ColumnWidths []ColumnWidth
+	// TableRowBreak specifies how the rows of a table that do not fit in the rest of the page are handled.
+	TableRowBreak TableRowBreak
+	// RepeatTableHeader draws the header row of a table again at the top of each page the table continues on.
+	RepeatTableHeader bool
+	// TableContinuationCaption is a caption such as "(continued)" drawn above the repeated header row.
+	TableContinuationCaption string
+	// DefinitionTermWidth is the width of the term column of a definition list.
+	// If it is zero, the descriptions are placed below their terms.
+	DefinitionTermWidth float64
+	// LineNumbers shows a gutter with line numbers on the left of a code block.
+	LineNumbers bool
+	// LineNumberColor is the color of the line numbers and the continuation markers of a code block.
+	// If it is nil, gray is used.
+	LineNumberColor color.Color
+	// Overflow specifies how the lines of a code block wider than its content box are handled.
+	Overflow Overflow
+	// WhiteSpace specifies how white space and line breaks in the text are handled.
+	WhiteSpace WhiteSpace
+	// TabSize is the distance between tab stops in spaces when white space is preserved.
+	// If it is zero, 4 is used.
+	TabSize int
+	// Justify stretches every line except the last line of a paragraph and the lines followed by a line break
+	// to the width of the content box. The other lines are aligned by TextAlign.
+	Justify bool
+	// TextJustify specifies where the extra space is distributed when Justify is true.
+	TextJustify TextJustify
+	// LineBreaker breaks the text into lines. If it is nil, LineBreakerGreedy is used.
+	LineBreaker LineBreaker
+	// HangingPunctuation allows the Japanese punctuation marks such as "、" and "。"
+	// to hang beyond the end of a line instead of pushing the previous character to the next line.
+	HangingPunctuation bool
+	// KeepWithNext moves the block to the next page along with the following block
+	// if the following block would otherwise start on the next page, e.g. to keep a heading with its text.
+	KeepWithNext bool
+	// AvoidBreakInside moves the block to the next page instead of breaking it, if it fits on a page.
+	AvoidBreakInside bool
+	// Orphans is the minimum number of lines of the block left at the bottom of a page when it is broken.
+	Orphans int
+	// Widows is the minimum number of lines of the block carried over to the top of the next page when it is broken.
+	Widows int
+	// PageBreakBefore starts the block on a new page.
+	PageBreakBefore bool
+	// PageBreakAfter starts the content following the block on a new page.
+	// It is ignored if no content follows the block.
+	PageBreakAfter bool
 }
 
+// TextJustify specifies where the extra space of a justified line is distributed.
+type TextJustify int
+
+const (
+	// TextJustifyInterWord distributes the extra space to the spaces between words.
+	TextJustifyInterWord TextJustify = iota
+	// TextJustifyInterCharacter also distributes the extra space between CJK characters,
+	// so that lines of text without spaces such as Japanese can be justified.
+	TextJustifyInterCharacter
+)
+
+// WhiteSpace specifies how white space and line breaks in the text are handled,
+// like the CSS white-space property.
+type WhiteSpace int
+
+const (
+	// WhiteSpaceNormal collapses sequences of spaces, tabs and soft line breaks into a single space and wraps lines.
+	WhiteSpaceNormal WhiteSpace = iota
+	// WhiteSpaceNoWrap collapses white space like WhiteSpaceNormal but does not wrap lines.
+	WhiteSpaceNoWrap
+	// WhiteSpacePre preserves white space and line breaks and does not wrap lines.
+	WhiteSpacePre
+	// WhiteSpacePreWrap preserves white space and line breaks and wraps lines.
+	WhiteSpacePreWrap
+)
+
+func (ws WhiteSpace) preserves() bool {
+	return ws == WhiteSpacePre || ws == WhiteSpacePreWrap
+}
+
+func (ws WhiteSpace) wraps() bool {
+	return ws == WhiteSpaceNormal || ws == WhiteSpacePreWrap
+}
+
+// ColumnWidth constrains the content width of a table column. Zero fields are not constrained.
+type ColumnWidth struct {
+	Min   float64 // the column is at least this wide even if its content is narrower
+	Max   float64 // the column is at most this wide even if its content is wider
+	Fixed float64 // the column is exactly this wide regardless of its content
+}
+
+// TableRowBreak specifies how a table row that does not fit in the rest of the page is handled.
+type TableRowBreak int
+
+const (
+	// TableRowBreakSplit breaks the row across pages.
+	// The boxes of its cells are closed at the bottom of the page and reopened at the top of the next page.
+	TableRowBreakSplit TableRowBreak = iota
+	// TableRowBreakKeep moves the whole row to the next page if it fits on a page.
+	TableRowBreakKeep
+)
+
+// Overflow specifies how the lines of a code block wider than its content box are handled.
+type Overflow int
+
+const (
+	// OverflowWrap wraps the lines and marks the end of each wrapped line with a continuation marker.
+	OverflowWrap Overflow = iota
+	// OverflowShrink shrinks the font of the whole block so that the longest line fits.
+	OverflowShrink
+	// OverflowClip clips the lines at the right edge of the content box.
+	OverflowClip
+)
+
 type TextFormat struct {
 	Color           color.Color
 	BackgroundColor color.Color
 	FontSize        float64
 	FontFamily      string
-	Bold            bool
-	Italic          bool
-	Strike          bool
-	Underline       bool
-	Border          UniformBorder
+	// FallbackFontFamilies are tried in order for the characters that FontFamily does not have.
+	// The glyph coverage of a family is known from the font data registered with WithFontCoverage.
+	FallbackFontFamilies []string
+	Bold                 bool
+	Italic               bool
+	Strike               bool
+	Underline            bool
+	Border               UniformBorder
+	BaselineShift        float64 // raises the text by this amount, e.g. for superscripts
+	// LineHeight is the height of the lines of the text as a multiple of FontSize.
+	// If it is zero, the lines are as high as the ascent and descent of the font.
+	LineHeight float64
 }
 
 type Styler interface {
@@ -36,25 +155,42 @@ type Styler interface {
 var _ Styler = &DefaultStyler{}
 
 type DefaultStyler struct {
-	FontFamily  string
-	FontSize    float64
-	Color       color.Color
-	TableLayout TableLayout
+	FontFamily          string
+	FontSize            float64
+	Color               color.Color
+	TableLayout         TableLayout
+	TableRowBreak       TableRowBreak
+	LineBreaker         LineBreaker
+	DefinitionTermWidth float64
+	// FallbackFontFamilies are used for the characters that FontFamily does not have.
+	FallbackFontFamilies []string
+	// TableContinuationCaption is drawn above the header row of a table repeated on the following pages.
+	TableContinuationCaption string
+	// ThematicBreakAsPageBreak makes thematic breaks start a new page instead of drawing a horizontal rule.
+	ThematicBreakAsPageBreak bool
+	// CodeLineNumbers shows the line numbers of code blocks.
+	CodeLineNumbers bool
+	// CodeOverflow specifies how the lines of code blocks wider than the page are handled.
+	CodeOverflow Overflow
 }
 
 func (s *DefaultStyler) Style(n ast.Node, tf TextFormat) (BlockStyle, TextFormat) {
-	bs := BlockStyle{TextAlign: xast.AlignNone}
+	bs := BlockStyle{TextAlign: xast.AlignNone, LineBreaker: s.LineBreaker}
 
 	switch n := n.(type) {
 	case *ast.Document:
 		tf.FontFamily = s.FontFamily
+		tf.FallbackFontFamilies = s.FallbackFontFamilies
 		tf.FontSize = s.FontSize
 		tf.Color = s.Color
 	case *ast.Heading:
 		tf.FontSize = s.FontSize * math.Pow(1.15, float64(7-n.Level))
 		bs.Margin = Spacing{Top: tf.FontSize / 2, Bottom: tf.FontSize / 2}
+		bs.KeepWithNext = true
 	case *ast.Paragraph:
 		bs.Margin = Spacing{Top: tf.FontSize / 2, Bottom: tf.FontSize / 2}
+		bs.Orphans = 2
+		bs.Widows = 2
 	case *ast.Blockquote:
 		bs.Padding = Spacing{Left: 10}
 		bs.Margin = Spacing{Top: tf.FontSize / 2, Bottom: tf.FontSize / 2}
@@ -65,6 +201,7 @@ func (s *DefaultStyler) Style(n ast.Node, tf TextFormat) (BlockStyle, TextFormat
 		bs.Margin = Spacing{Top: tf.FontSize / 2, Bottom: tf.FontSize / 2}
 	case *ast.ListItem:
 		bs.Padding = Spacing{Left: 16}
+		bs.AvoidBreakInside = true
 	case *ast.Link, *ast.AutoLink:
 		tf.Color = color.RGBA{B: 0xFF, A: 0xFF}
 		tf.Underline = true
@@ -79,19 +216,61 @@ func (s *DefaultStyler) Style(n ast.Node, tf TextFormat) (BlockStyle, TextFormat
 		tf.BackgroundColor = color.Gray{Y: 0xF2}
 		tf.Border = UniformBorder{Width: 0.5, Color: color.Gray{Y: 0x99}, Radius: 3}
 	case *ast.CodeBlock, *ast.FencedCodeBlock:
+		bs.WhiteSpace = WhiteSpacePreWrap
+		bs.AvoidBreakInside = true
+		bs.LineNumbers = s.CodeLineNumbers
+		bs.Overflow = s.CodeOverflow
 		bs.BackgroundColor = color.Gray{Y: 0xF2}
 		bs.Margin = Spacing{Top: 10, Bottom: 10}
 		bs.Border = UniformBorder{Width: 0.5, Color: color.Gray{Y: 0x99}, Radius: 3}
 		bs.Padding = Spacing{Top: 10, Left: 10, Bottom: 10, Right: 10}
 	case *ast.ThematicBreak:
+		if s.ThematicBreakAsPageBreak {
+			bs.PageBreakAfter = true
+			break
+		}
 		bs.Margin = Spacing{Top: 19, Bottom: 19}
 		bs.Border = IndividualBorder{
 			Top: BorderEdge{Width: 2, Color: color.Gray{Y: 0x80}},
 		}
+	case *TableOfContents:
+		bs.Margin = Spacing{Top: tf.FontSize / 2, Bottom: tf.FontSize / 2}
+	case *TableOfContentsEntry:
+		bs.Margin = Spacing{Left: tf.FontSize * float64(n.Heading.Level-1), Top: tf.FontSize / 4, Bottom: tf.FontSize / 4}
+		tf.Bold = n.Heading.Level == 1
+	case *xast.DefinitionList:
+		bs.DefinitionTermWidth = s.DefinitionTermWidth
+		bs.Margin = Spacing{Top: tf.FontSize / 2, Bottom: tf.FontSize / 2}
+	case *xast.DefinitionTerm:
+		tf.Bold = true
+		bs.Margin = Spacing{Top: tf.FontSize / 4}
+	case *xast.DefinitionDescription:
+		bs.Margin = Spacing{Left: 24, Top: tf.FontSize / 4}
+	case *xast.TaskCheckBox:
+		// The font size is the size of the box and the text color is the color of the check mark
+		tf.FontSize *= 0.8
+		bs.BackgroundColor = color.White
+		bs.Border = UniformBorder{Width: 0.75, Color: color.Gray{Y: 0x40}, Radius: 1.5}
+		bs.Padding = Spacing{Left: 1, Top: 1, Right: 1, Bottom: 1}
+	case *xast.FootnoteLink:
+		tf.BaselineShift = tf.FontSize * 0.4
+		tf.FontSize *= 0.7
+	case *xast.FootnoteList:
+		tf.FontSize *= 0.85
+		bs.Margin = Spacing{Top: 19, Bottom: 10}
+		bs.Padding = Spacing{Top: tf.FontSize / 2}
+		bs.Border = IndividualBorder{
+			Top: BorderEdge{Width: 0.5, Color: color.Gray{Y: 0x80}},
+		}
+	case *xast.Footnote:
+		bs.Padding = Spacing{Left: 16}
 	case *xast.Strikethrough:
 		tf.Strike = true
 	case *xast.Table:
 		bs.TableLayout = s.TableLayout
+		bs.TableRowBreak = s.TableRowBreak
+		bs.RepeatTableHeader = true
+		bs.TableContinuationCaption = s.TableContinuationCaption
 		bs.Margin = Spacing{Top: 10, Bottom: 10}
 	case *xast.TableHeader:
 		tf.Bold = true
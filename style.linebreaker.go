@@ -0,0 +1,204 @@
+package goldpdf
+
+import (
+	"math"
+	"unicode"
+)
+
+// LineBreaker splits inline elements into lines that fit in limitWidth.
+// LineBreakElements in the elements always start a new line and are not included in the result.
+type LineBreaker func(mc MeasureContext, limitWidth float64, elements []InlineElement, bs BlockStyle) [][]InlineElement
+
+var (
+	_ LineBreaker = LineBreakerGreedy
+	_ LineBreaker = LineBreakerOptimal
+)
+
+// LineBreakerGreedy is a LineBreaker that puts as many words as possible on each line.
+func LineBreakerGreedy(mc MeasureContext, limitWidth float64, elements []InlineElement, bs BlockStyle) [][]InlineElement {
+	return wrapElements(mc, limitWidth, elements, bs)
+}
+
+// LineBreakerOptimal is a LineBreaker that chooses the breaks minimizing the total badness of the lines
+// of a whole paragraph, like the Knuth–Plass algorithm of TeX.
+// The badness of a line grows with the space that has to be added to justify it,
+// so the lines become more even than those of LineBreakerGreedy.
+// Paragraphs that cannot be broken without overflowing, such as those with overlong words,
+// are broken by LineBreakerGreedy. BlockStyle.HangingPunctuation is not supported.
+func LineBreakerOptimal(mc MeasureContext, limitWidth float64, elements []InlineElement, bs BlockStyle) [][]InlineElement {
+	if !bs.WhiteSpace.wraps() {
+		return wrapElements(mc, limitWidth, elements, bs)
+	}
+
+	result := [][]InlineElement{}
+	for _, paragraph := range splitLineBreaks(elements) {
+		lines := breakParagraphOptimally(mc, limitWidth, paragraph)
+		if lines == nil {
+			lines = wrapElements(mc, limitWidth, paragraph, bs)
+		}
+		result = append(result, lines...)
+	}
+	return result
+}
+
+// getLineBreaker returns the LineBreaker of the BlockStyle, or LineBreakerGreedy if it is not set.
+func getLineBreaker(bs BlockStyle) LineBreaker {
+	if bs.LineBreaker != nil {
+		return bs.LineBreaker
+	}
+	return LineBreakerGreedy
+}
+
+// lineItem is a unit of a paragraph for optimal line breaking.
+type lineItem struct {
+	elements []InlineElement
+	width    float64
+	glue     bool // spaces, which are removed at a break and stretched for justification
+}
+
+// lineBreak is a position where a line can be broken.
+// The line ends before items[end] and the next line starts with items[next].
+type lineBreak struct {
+	end, next int
+}
+
+// linePenalty is added to the badness of every line so that fewer lines are preferred.
+const linePenalty = 10
+
+// breakParagraphOptimally breaks a paragraph without line breaks into lines with the minimum total demerits.
+// It returns nil if the paragraph cannot be broken so that every line fits.
+func breakParagraphOptimally(mc MeasureContext, limitWidth float64, paragraph []InlineElement) [][]InlineElement {
+	items := getLineItems(mc, paragraph)
+
+	breaks := []lineBreak{{end: 0, next: 0}}
+	for i, item := range items {
+		if item.glue {
+			breaks = append(breaks, lineBreak{end: i, next: i + 1})
+		} else if i > 0 && !items[i-1].glue && (isCJKItem(items[i-1]) || isCJKItem(item)) && !violatesKinsoku(lastRune(items[i-1].elements), firstRune(item.elements)) {
+			breaks = append(breaks, lineBreak{end: i, next: i})
+		}
+	}
+	breaks = append(breaks, lineBreak{end: len(items), next: len(items)})
+
+	// Prefix sums of the widths and of the stretchability of the items
+	widths := make([]float64, len(items)+1)
+	stretches := make([]float64, len(items)+1)
+	for i, item := range items {
+		widths[i+1] = widths[i] + item.width
+		stretches[i+1] = stretches[i]
+		if item.glue {
+			stretches[i+1] += item.width / 2
+		}
+	}
+
+	demerits := make([]float64, len(breaks))
+	previous := make([]int, len(breaks))
+	for j := 1; j < len(breaks); j++ {
+		demerits[j] = math.Inf(1)
+		for i := j - 1; i >= 0; i-- {
+			start, end := breaks[i].next, breaks[j].end
+			if start > end {
+				continue
+			}
+			width := widths[end] - widths[start]
+			if width > limitWidth {
+				break // the lines starting at earlier breaks are even wider
+			}
+			if math.IsInf(demerits[i], 1) {
+				continue
+			}
+
+			badness := 0.0
+			if j != len(breaks)-1 { // the last line is not stretched
+				// A line without spaces gets a tiny stretchability so that its badness still reflects its slack
+				stretch := math.Max(stretches[end]-stretches[start], limitWidth/1000)
+				badness = 100 * math.Pow((limitWidth-width)/stretch, 3)
+			}
+
+			if d := demerits[i] + math.Pow(linePenalty+badness, 2); d < demerits[j] {
+				demerits[j] = d
+				previous[j] = i
+			}
+		}
+	}
+
+	last := len(breaks) - 1
+	if math.IsInf(demerits[last], 1) {
+		return nil
+	}
+
+	lines := [][]InlineElement{}
+	for j := last; j != 0; j = previous[j] {
+		line := []InlineElement{}
+		for _, item := range items[breaks[previous[j]].next:breaks[j].end] {
+			line = append(line, item.elements...)
+		}
+		lines = append([][]InlineElement{line}, lines...)
+	}
+	if len(lines) == 0 {
+		lines = append(lines, []InlineElement{})
+	}
+	return lines
+}
+
+// getLineItems splits the elements into words, spaces and CJK characters.
+// Parts of a word in different elements, such as a partially emphasized word, belong to the same item.
+func getLineItems(mc MeasureContext, elements []InlineElement) []lineItem {
+	items := []lineItem{}
+	current := lineItem{}
+	flush := func() {
+		if len(current.elements) != 0 {
+			items = append(items, current)
+		}
+		current = lineItem{}
+	}
+	add := func(e InlineElement, glue bool) {
+		if current.glue != glue {
+			flush()
+		}
+		w, _ := e.size(mc)
+		current.elements = append(current.elements, e)
+		current.width += w
+		current.glue = glue
+	}
+
+	for _, e := range elements {
+		t, ok := e.(*TextElement)
+		if !ok {
+			add(e, false)
+			continue
+		}
+		if t.Text == "" {
+			add(t, current.glue)
+			continue
+		}
+
+		runes := []rune(t.Text)
+		start := 0
+		for i := 1; i <= len(runes); i++ {
+			if i < len(runes) && unicode.IsSpace(runes[i]) == unicode.IsSpace(runes[i-1]) && !isCJK(runes[i]) && !isCJK(runes[i-1]) {
+				continue
+			}
+			e2 := *t
+			e2.Text = string(runes[start:i])
+			if isCJK(runes[start]) {
+				flush()
+				add(&e2, false)
+				flush()
+			} else {
+				add(&e2, unicode.IsSpace(runes[start]))
+			}
+			start = i
+		}
+	}
+	flush()
+	return items
+}
+
+func isCJKItem(item lineItem) bool {
+	if len(item.elements) != 1 {
+		return false
+	}
+	e, ok := item.elements[0].(*TextElement)
+	return ok && len([]rune(e.Text)) == 1 && isCJK([]rune(e.Text)[0])
+}
@@ -37,37 +37,136 @@ func TableLayoutAutoCompact(r *Renderer, n *xast.Table, mc MeasureContext, borde
 	return tableLayoutAuto(r, n, mc, borderBox, false)
 }
 
+// tableLayoutAuto lays out the columns like the automatic table layout of web browsers.
+// Each column is at least as wide as its longest unbreakable run (min-content width) if possible,
+// and the rest of the available width is distributed in proportion to how much wider
+// the column would be with its contents on single lines (max-content width).
+// If filled is true, the columns without a fixed or maximum width are widened further to fill the table.
 func tableLayoutAuto(r *Renderer, n *xast.Table, mc MeasureContext, borderBox HalfBounds, filled bool) ([]float64, error) {
+	minWidths, maxWidths, err := getColumnContentWidths(r, n, mc)
+	if err != nil {
+		return nil, err
+	}
+
+	// Apply the constraints from the Styler.
+	// Columns with a fixed or maximum width are not flexible: they are neither widened to fill the table
+	// nor narrowed below their min-content width when the table overflows.
+	columnWidths := r.blockStyle(n).ColumnWidths
+	flexible := make([]bool, len(n.Alignments))
+	for i := range flexible {
+		flexible[i] = true
+		if i < len(columnWidths) {
+			cw := columnWidths[i]
+			if cw.Min != 0 {
+				minWidths[i] = math.Max(minWidths[i], cw.Min)
+				maxWidths[i] = math.Max(maxWidths[i], cw.Min)
+			}
+			if cw.Max != 0 {
+				minWidths[i] = math.Min(minWidths[i], cw.Max)
+				maxWidths[i] = math.Min(maxWidths[i], cw.Max)
+				flexible[i] = false
+			}
+			if cw.Fixed != 0 {
+				minWidths[i], maxWidths[i] = cw.Fixed, cw.Fixed
+				flexible[i] = false
+			}
+		}
+	}
+
+	availableWidth := getAvailableCellContentWidths(r, n, borderBox)
+	totalMin, totalMax := sum(minWidths), sum(maxWidths)
+
 	columnContentWidth := make([]float64, len(n.Alignments))
+	switch {
+	case totalMax <= availableWidth:
+		// Every column fits on single lines
+		copy(columnContentWidth, maxWidths)
+		if filled {
+			growColumns(columnContentWidth, flexible, availableWidth-totalMax)
+		}
+	case totalMin < availableWidth:
+		// Columns wider than their min-content width share the rest of the available width
+		ratio := (availableWidth - totalMin) / (totalMax - totalMin)
+		for i := range columnContentWidth {
+			columnContentWidth[i] = minWidths[i] + (maxWidths[i]-minWidths[i])*ratio
+		}
+	default:
+		// Words cannot but be broken, so the min-content widths of the flexible columns
+		// are scaled down evenly to the width left by the other columns
+		flexibleMin := 0.0
+		for i, w := range minWidths {
+			if flexible[i] {
+				flexibleMin += w
+			} else {
+				availableWidth -= w
+			}
+		}
+		for i := range columnContentWidth {
+			columnContentWidth[i] = minWidths[i]
+			if flexible[i] && flexibleMin != 0 {
+				columnContentWidth[i] *= math.Max(availableWidth, 0) / flexibleMin
+			}
+		}
+	}
+
+	return columnContentWidth, nil
+}
+
+// getColumnContentWidths returns the min-content and max-content widths of the contents of each column.
+func getColumnContentWidths(r *Renderer, n *xast.Table, mc MeasureContext) ([]float64, []float64, error) {
+	minWidths := make([]float64, len(n.Alignments))
+	maxWidths := make([]float64, len(n.Alignments))
 
 	for row := n.FirstChild(); row != nil; row = row.NextSibling() {
 		colIndex := 0
 		for col := row.FirstChild(); col != nil; col = col.NextSibling() {
 			elements, err := r.getFlowElements(col)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
+			}
+
+			naturalWidth := GetNaturalWidth(mc, elements)
+			minWidth := naturalWidth
+			if r.blockStyle(col).WhiteSpace.wraps() {
+				minWidth = GetMinContentWidth(mc, elements)
 			}
-			columnContentWidth[colIndex] = math.Max(columnContentWidth[colIndex], GetNaturalWidth(mc, elements))
+			minWidths[colIndex] = math.Max(minWidths[colIndex], minWidth)
+			maxWidths[colIndex] = math.Max(maxWidths[colIndex], naturalWidth)
 			colIndex++
 		}
 	}
+	return minWidths, maxWidths, nil
+}
 
-	availableWidth := getAvailableCellContentWidths(r, n, borderBox)
-
-	totalWidth := 0.0
-	for _, ccw := range columnContentWidth {
-		totalWidth += ccw
+// growColumns distributes the extra width to the flexible columns in proportion to their widths,
+// or evenly if they are all empty. If no column is flexible, the extra width is left unfilled.
+func growColumns(widths []float64, flexible []bool, extra float64) {
+	count, total := 0, 0.0
+	for i, w := range widths {
+		if flexible[i] {
+			count++
+			total += w
+		}
 	}
 
-	// 列の最大幅がavailableWidthを超過するなら
-	if filled || totalWidth > availableWidth {
-		// 列の最大幅を均等倍率で縮小する
-		for i := range columnContentWidth {
-			columnContentWidth[i] *= availableWidth / totalWidth
+	for i := range widths {
+		if !flexible[i] {
+			continue
+		}
+		if total == 0 {
+			widths[i] += extra / float64(count)
+		} else {
+			widths[i] += extra * widths[i] / total
 		}
 	}
+}
 
-	return columnContentWidth, nil
+func sum(values []float64) float64 {
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	return total
 }
 
 func getAvailableCellContentWidths(r *Renderer, n *xast.Table, borderBox HalfBounds) float64 {
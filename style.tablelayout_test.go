@@ -0,0 +1,98 @@
+package goldpdf
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	xast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// columnWidthStyler constrains the widths of the table columns.
+type columnWidthStyler struct {
+	DefaultStyler
+	columnWidths []ColumnWidth
+}
+
+func (s *columnWidthStyler) Style(n ast.Node, tf TextFormat) (BlockStyle, TextFormat) {
+	bs, tf := s.DefaultStyler.Style(n, tf)
+	if _, ok := n.(*xast.Table); ok {
+		bs.ColumnWidths = s.columnWidths
+	}
+	return bs, tf
+}
+
+func TestTableLayoutAuto(t *testing.T) {
+	const (
+		wide     = "| ID | Code | Description |\n|---|---|---|\n| 1 | ABCDEF-123456 | lorem ipsum dolor sit amet lorem ipsum dolor sit amet lorem ipsum dolor sit amet lorem ipsum dolor sit amet |\n"
+		short    = "| A | B |\n|---|---|\n| x | y |\n"
+		overflow = "| ID | Code | Description |\n|---|---|---|\n| 1 | ABCDEFGHIJKLMNOP | QRSTUVWXYZABCDEFGHIJ |\n"
+	)
+	mc := &renderContextImpl{fpdf: gofpdf.New("P", "pt", "A4", "")}
+
+	// check reports whether the widths are right, given the min-content widths and the available width
+	type check func(minWidths, widths []float64, available float64) bool
+	fillsTable := func(minWidths, widths []float64, available float64) bool {
+		return math.Abs(sum(widths)-available) < 1e-9
+	}
+
+	tests := []struct {
+		name         string
+		source       string
+		layout       TableLayout
+		width        float64
+		columnWidths []ColumnWidth
+		check        check
+	}{
+		{"min-content", wide, TableLayoutAutoCompact, 400, nil, func(minWidths, widths []float64, available float64) bool {
+			return widths[0] >= minWidths[0] && widths[1] >= minWidths[1] && fillsTable(minWidths, widths, available)
+		}},
+		{"fixed", wide, TableLayoutAutoCompact, 400, []ColumnWidth{{}, {Fixed: 50}}, func(minWidths, widths []float64, available float64) bool {
+			return widths[1] == 50 && widths[0] >= minWidths[0] && fillsTable(minWidths, widths, available)
+		}},
+		{"min", wide, TableLayoutAutoCompact, 400, []ColumnWidth{{Min: 60}}, func(minWidths, widths []float64, available float64) bool {
+			return widths[0] >= 60 && fillsTable(minWidths, widths, available)
+		}},
+		{"compact", short, TableLayoutAutoCompact, 400, nil, func(minWidths, widths []float64, available float64) bool {
+			return widths[0] == minWidths[0] && widths[1] == minWidths[1]
+		}},
+		{"filled", short, TableLayoutAutoFilled, 400, nil, fillsTable},
+		{"filled with a maximum width", short, TableLayoutAutoFilled, 400, []ColumnWidth{{Max: 10}}, func(minWidths, widths []float64, available float64) bool {
+			return widths[0] <= 10 && fillsTable(minWidths, widths, available)
+		}},
+		{"filled with fixed widths only", short, TableLayoutAutoFilled, 400, []ColumnWidth{{Fixed: 20}, {Fixed: 30}}, func(minWidths, widths []float64, available float64) bool {
+			return widths[0] == 20 && widths[1] == 30
+		}},
+		{"overflow", overflow, TableLayoutAutoCompact, 100, nil, fillsTable},
+		{"overflow with a fixed width", overflow, TableLayoutAutoCompact, 100, []ColumnWidth{{}, {Fixed: 20}}, func(minWidths, widths []float64, available float64) bool {
+			return widths[1] == 20 && widths[0] < minWidths[0] && fillsTable(minWidths, widths, available)
+		}},
+	}
+	for _, tt := range tests {
+		source := []byte(tt.source)
+		table := goldmark.New(goldmark.WithExtensions(extension.Table)).Parser().Parse(text.NewReader(source)).FirstChild().(*xast.Table)
+		borderBox := HalfBounds{Left: 0, Right: tt.width}
+
+		styler := &columnWidthStyler{DefaultStyler{FontFamily: "Arial", FontSize: 12, Color: color.Black}, tt.columnWidths}
+		r := newRenderer(WithStyler(styler))
+		r.source = source
+
+		minWidths, _, err := getColumnContentWidths(r, table, mc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		widths, err := tt.layout(r, table, mc, borderBox)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !tt.check(minWidths, widths, getAvailableCellContentWidths(r, table, borderBox)) {
+			t.Errorf("%s: widths = %v, min-content widths = %v", tt.name, widths, minWidths)
+		}
+	}
+}
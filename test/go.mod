@@ -12,6 +12,8 @@ require (
 )
 
 require (
+	github.com/alecthomas/chroma/v2 v2.12.0 // indirect
+	github.com/dlclark/regexp2 v1.10.0 // indirect
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
 	github.com/raykov/css-font-parser v0.3.0 // indirect
 	github.com/raykov/oksvg v0.0.5 // indirect
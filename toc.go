@@ -0,0 +1,123 @@
+package goldpdf
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// TableOfContentsPlacement specifies where the table of contents is inserted into the document.
+type TableOfContentsPlacement int
+
+const (
+	// TableOfContentsNone does not insert a table of contents.
+	TableOfContentsNone TableOfContentsPlacement = iota
+	// TableOfContentsAtTop inserts a table of contents at the beginning of the document.
+	TableOfContentsAtTop
+	// TableOfContentsAtMarker replaces each paragraph consisting only of TableOfContentsMarker with a table of contents.
+	TableOfContentsAtMarker
+)
+
+// TableOfContentsMarker is the paragraph text replaced by TableOfContentsAtMarker.
+const TableOfContentsMarker = "[TOC]"
+
+var (
+	KindTableOfContents      = ast.NewNodeKind("TableOfContents")
+	KindTableOfContentsEntry = ast.NewNodeKind("TableOfContentsEntry")
+)
+
+var (
+	_ ast.Node = &TableOfContents{}
+	_ ast.Node = &TableOfContentsEntry{}
+)
+
+// TableOfContents is a block node that lists the headings of the document.
+// Its children are TableOfContentsEntry nodes.
+type TableOfContents struct {
+	ast.BaseBlock
+}
+
+func (n *TableOfContents) Kind() ast.NodeKind {
+	return KindTableOfContents
+}
+
+func (n *TableOfContents) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+// TableOfContentsEntry is a block node representing a single heading in the TableOfContents.
+type TableOfContentsEntry struct {
+	ast.BaseBlock
+	Heading *ast.Heading
+}
+
+func (n *TableOfContentsEntry) Kind() ast.NodeKind {
+	return KindTableOfContentsEntry
+}
+
+func (n *TableOfContentsEntry) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Level": fmt.Sprint(n.Heading.Level)}, nil)
+}
+
+// insertTableOfContents inserts TableOfContents nodes into the document according to the placement
+// and reports whether the document contains a table of contents.
+// The returned function removes the inserted nodes to leave the document as it was.
+func (r *Renderer) insertTableOfContents(doc ast.Node, placement TableOfContentsPlacement) (bool, func(), error) {
+	headings := []*ast.Heading{}
+	markers := []ast.Node{}
+	found := false
+
+	err := ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *TableOfContents:
+			found = true // placed in the document by the user
+			return ast.WalkSkipChildren, nil
+		case *ast.Heading:
+			headings = append(headings, n)
+		case *ast.Paragraph:
+			if strings.TrimSpace(string(n.Text(r.source))) == TableOfContentsMarker {
+				markers = append(markers, n)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	if err != nil {
+		return false, nil, err
+	}
+
+	newTableOfContents := func() *TableOfContents {
+		toc := &TableOfContents{}
+		for _, h := range headings {
+			toc.AppendChild(toc, &TableOfContentsEntry{Heading: h})
+		}
+		return toc
+	}
+
+	restores := []func(){}
+	switch {
+	case found:
+	case placement == TableOfContentsAtTop:
+		toc := newTableOfContents()
+		doc.InsertBefore(doc, doc.FirstChild(), toc)
+		restores = append(restores, func() { doc.RemoveChild(doc, toc) })
+		found = true
+	case placement == TableOfContentsAtMarker:
+		for _, m := range markers {
+			m, parent, toc := m, m.Parent(), newTableOfContents()
+			parent.ReplaceChild(parent, m, toc)
+			restores = append(restores, func() { parent.ReplaceChild(parent, toc, m) })
+			found = true
+		}
+	}
+
+	restore := func() {
+		for _, f := range restores {
+			f()
+		}
+	}
+	return found, restore, nil
+}
@@ -0,0 +1,83 @@
+package goldpdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+func TestTableOfContents(t *testing.T) {
+	// The table of contents is longer than a page, so the headings move to later pages after the first pass
+	source := ""
+	for i := 1; i <= 80; i++ {
+		source += fmt.Sprintf("# H%d\n\ntext\n\n", i)
+	}
+	sourceBytes := []byte(source)
+	doc := goldmark.New(goldmark.WithParserOptions(parser.WithAutoHeadingID())).Parser().Parse(text.NewReader(sourceBytes))
+	countNodes := func() int {
+		count := 0
+		ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+			if entering {
+				count++
+			}
+			return ast.WalkContinue, nil
+		})
+		return count
+	}
+	nodes := countNodes()
+
+	r := New(WithTableOfContents(TableOfContentsAtTop), WithPDFProvider(func() *gofpdf.Fpdf {
+		fpdf := gofpdf.New(gofpdf.OrientationPortrait, "pt", "A4", ".")
+		fpdf.SetCompression(false)
+		return fpdf
+	}))
+
+	// Rendering the same document twice gives the same result
+	outputs := []string{}
+	for i := 0; i < 2; i++ {
+		buf := &bytes.Buffer{}
+		if err := r.Render(buf, sourceBytes, doc); err != nil {
+			t.Fatal(err)
+		}
+		outputs = append(outputs, buf.String())
+
+		if got := countNodes(); got != nodes {
+			t.Errorf("render #%d left %d nodes in the document, want %d", i+1, got, nodes)
+		}
+	}
+	if strings.Count(outputs[1], "(H1) Tj") != 2 {
+		t.Errorf("the second render does not draw the heading once in the table of contents and once in the body")
+	}
+
+	// The title, the leader and the page number of each entry link to the heading
+	if got := strings.Count(outputs[1], "/Dest ["); got != 80*3 {
+		t.Errorf("%d links are added to the table of contents, want %d", got, 80*3)
+	}
+
+	// Each entry shows the page where its heading is drawn
+	output := outputs[1]
+	pages := pageContents(output)
+	for _, title := range []string{"H1", "H40", "H80"} {
+		m := regexp.MustCompile(`\(` + title + `\) Tj[^()]*\((\d+)\) Tj`).FindStringSubmatch(output)
+		if m == nil {
+			t.Fatalf("the entry of %s has no page number", title)
+		}
+		page := 0
+		for i, contents := range pages {
+			if strings.Contains(contents, "("+title+") Tj") {
+				page = i + 1
+			}
+		}
+		if want := fmt.Sprint(page); m[1] != want {
+			t.Errorf("the entry of %s shows page %s, want %s", title, m[1], want)
+		}
+	}
+}